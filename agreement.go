@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	AgreementDoc                   DocType = "agreement"
+	AgreementStatusChangedEventKey         = "agreement_status_changed"
+)
+
+//Represents data stored in database
+//Contains the doctype
+//An agreement is opened when an offer is accepted and follows the same statuses as a transaction
+//The offering organization acts as the ORDER party and the requesting organization as the TRANSACTION party
+//The value of an agreement for a private offer is kept private as well, Value is then empty and ValueHash holds the hash of the private record
+type AgreementInner struct {
+	Doc
+
+	ID                      string            `json:"id"`
+	Value                   Price             `json:"value"`
+	PrivateValue            bool              `json:"private_value"`
+	ValueHash               string            `json:"value_hash,omitempty"`
+	Description             string            `json:"description"`
+	Status                  TransactionStatus `json:"status"`
+	OrganizationID          string            `json:"organization_id"`
+	RequesterOrganizationID string            `json:"requester_organization_id"`
+	RequestID               string            `json:"request_id"`
+	OfferID                 string            `json:"offer_id"`
+}
+
+type Agreement struct {
+	ID                      string            `json:"id"`
+	Value                   Price             `json:"value"`
+	PrivateValue            bool              `json:"private_value"`
+	ValueHash               string            `json:"value_hash,omitempty"`
+	Description             string            `json:"description"`
+	Status                  TransactionStatus `json:"status"`
+	OrganizationID          string            `json:"organization_id"`
+	RequesterOrganizationID string            `json:"requester_organization_id"`
+	RequestID               string            `json:"request_id"`
+	OfferID                 string            `json:"offer_id"`
+	CreatedAt               time.Time         `json:"created_at"`
+	UpdatedAt               time.Time         `json:"updated_at"`
+	Archived                bool              `json:"archived,omitempty"`
+	ArchivedAt              time.Time         `json:"archived_at"`
+	Owner                   string            `json:"owner"`
+}
+
+type AgreementStatusChangedEvent struct {
+	AgreementID string            `json:"agreement_id"`
+	OldStatus   TransactionStatus `json:"old_status"`
+	NewStatus   TransactionStatus `json:"new_status"`
+	Message     string            `json:"message"`
+}
+
+//Parse agreement from the data on the database
+func (s *SmartContract) FromAgreementInner(_ contractapi.TransactionContextInterface, p *AgreementInner) *Agreement {
+	return &Agreement{
+		ID: p.ID,
+		Value: Price{
+			Amount:   p.Value.Amount,
+			Exponent: p.Value.Exponent,
+			Currency: p.Value.Currency,
+		},
+		PrivateValue:            p.PrivateValue,
+		ValueHash:               p.ValueHash,
+		Description:             p.Description,
+		Status:                  p.Status,
+		OrganizationID:          p.OrganizationID,
+		RequesterOrganizationID: p.RequesterOrganizationID,
+		RequestID:               p.RequestID,
+		OfferID:                 p.OfferID,
+		CreatedAt:               p.CreatedAt,
+		UpdatedAt:               p.UpdatedAt,
+		Archived:                p.Archived,
+		ArchivedAt:              p.ArchivedAt,
+		Owner:                   p.Owner,
+	}
+}
+
+func (s *SmartContract) GetAgreementID(_ contractapi.TransactionContextInterface, id string) string {
+	return string(AgreementDoc) + "_" + id
+}
+
+func NewAgreementStatusChangedEvent(id string, oldStatus TransactionStatus, newStatus TransactionStatus, message string) ([]byte, error) {
+	return json.Marshal(AgreementStatusChangedEvent{AgreementID: id, OldStatus: oldStatus, NewStatus: newStatus, Message: message})
+}
+
+//Checks if agreement with the given ID exists
+func (s *SmartContract) AgreementExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	assetJSON, err := ctx.GetStub().GetState(s.GetAgreementID(ctx, id))
+	if err != nil {
+		return false, newError(ErrInternal, "failed to read from world state:%v", err)
+	}
+
+	return assetJSON != nil, nil
+}
+
+//Opens the agreement for the accepted offer of the request
+//The agreement shares the ID of the request, as a request can only be awarded once
+//The private value of an offer is copied to the agreement key in the collections of both organizations, so it keeps matching the hash of the offer
+func (s *SmartContract) createAgreement(ctx contractapi.TransactionContextInterface, request *RequestInner, offer *OfferInner, clientID string) error {
+	exists, err := s.AgreementExist(ctx, request.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return newError(ErrAlreadyExists, "the asset %s already exists", request.ID)
+	}
+
+	doc, err := s.newDoc(ctx, AgreementDoc, clientID)
+	if err != nil {
+		return err
+	}
+
+	agreement := AgreementInner{
+		Doc:                     doc,
+		ID:                      request.ID,
+		Value:                   offer.Value,
+		PrivateValue:            offer.PrivateValue,
+		ValueHash:               offer.ValueHash,
+		Description:             request.Description,
+		Status:                  TransactionStatusOpen,
+		OrganizationID:          offer.OrganizationID,
+		RequesterOrganizationID: request.OrganizationID,
+		RequestID:               request.ID,
+		OfferID:                 offer.ID,
+	}
+
+	if offer.PrivateValue {
+		priceBytes, err := s.getPrivatePriceBytes(ctx, s.GetOfferID(ctx, offer.ID), offer.ValueHash, offer.Owner, request.Owner)
+		if err != nil {
+			return err
+		}
+
+		if err := s.putPrivatePriceBytes(ctx, s.GetAgreementID(ctx, agreement.ID), priceBytes, offer.Owner, request.Owner); err != nil {
+			return err
+		}
+	}
+
+	return s.putAgreementInner(ctx, &agreement)
+}
+
+//Updates the status of the agreement with the given ID with the given status and description
+//Follows the transaction transition table, admins can act as any party
+func (s *SmartContract) ChangeAgreementStatus(ctx contractapi.TransactionContextInterface, id string, inputStatus string, message string) error {
+	if err := validate(
+		field("id", id, required),
+		field("message", message, maxLength(MaxDescriptionLength)),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, TransactionsUpdate); err != nil {
+		return err
+	}
+
+	status, err := ParseTransactionStatus(inputStatus)
+	if err != nil {
+		return err
+	}
+
+	agreement, err := s.GetAgreementInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	orgID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+
+	offerMSPID, requesterMSPID, err := s.getAgreementMSPIDs(ctx, agreement)
+	if err != nil {
+		return err
+	}
+
+	var parties []TransactionParty
+	if orgID == offerMSPID {
+		parties = append(parties, TransactionPartyOrder)
+	}
+
+	if orgID == requesterMSPID {
+		parties = append(parties, TransactionPartyTransaction)
+	}
+
+	parties = s.withAdminParties(ctx, parties)
+	if err := checkTransactionTransition(id, agreement.Status, status, parties); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldStatus := agreement.Status
+	agreement.Status = status
+	agreement.Description = message
+	agreement.UpdatedBy = clientID
+
+	err = s.putAgreementInner(ctx, agreement)
+	if err != nil {
+		return err
+	}
+
+	eventBody, err := NewAgreementStatusChangedEvent(agreement.ID, oldStatus, status, message)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(AgreementStatusChangedEventKey, eventBody)
+}
+
+//Archives the agreement with the given ID
+//Only a party of the agreement can delete it, and only once it is closed or canceled
+func (s *SmartContract) DeleteAgreement(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, TransactionsDelete); err != nil {
+		return err
+	}
+
+	agreement, err := s.GetAgreementInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	offerMSPID, requesterMSPID, err := s.getAgreementMSPIDs(ctx, agreement)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, offerMSPID, requesterMSPID); err != nil {
+		return err
+	}
+
+	if !isTransactionFinal(agreement.Status) {
+		return newError(ErrInvalidState, "agreement %s is %s, only closed or canceled agreements can be deleted", id, agreement.Status)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&agreement.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	return s.putAgreementInner(ctx, agreement)
+}
+
+//Restores the archived agreement with the given ID
+//Restoring does not change the status of the agreement
+func (s *SmartContract) RestoreAgreement(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, TransactionsDelete); err != nil {
+		return err
+	}
+
+	agreement, err := s.GetAgreementInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	offerMSPID, requesterMSPID, err := s.getAgreementMSPIDs(ctx, agreement)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, offerMSPID, requesterMSPID); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&agreement.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putAgreementInner(ctx, agreement)
+}
+
+//Returns the MSP IDs of the offering and the requesting organization of the agreement
+//The agreement is owned by the requesting organization, which accepted the offer
+func (s *SmartContract) getAgreementMSPIDs(ctx contractapi.TransactionContextInterface, agreement *AgreementInner) (string, string, error) {
+	offerMSPID, err := s.getOrganizationMSPID(ctx, agreement.OrganizationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return offerMSPID, agreement.Owner, nil
+}
+
+//Stores the given AgreementInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetAgreementInner
+func (s *SmartContract) putAgreementInner(ctx contractapi.TransactionContextInterface, agreement *AgreementInner) error {
+	if err := s.touchDoc(ctx, &agreement.Doc); err != nil {
+		return err
+	}
+
+	stored := *agreement
+	stored.ID = s.GetAgreementID(ctx, agreement.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
+	}
+
+	return s.putReferenceIndexes(ctx, AgreementDoc, agreement.ID, assetBytes)
+}
+
+//Returns AgreementInner with the given ID
+func (s *SmartContract) GetAgreementInner(ctx contractapi.TransactionContextInterface, id string) (*AgreementInner, error) {
+	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
+		return nil, err
+	}
+
+	assetBytes, err := ctx.GetStub().GetState(s.GetAgreementID(ctx, id))
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
+	}
+
+	if assetBytes == nil {
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
+	}
+
+	var a AgreementInner
+	err = json.Unmarshal(assetBytes, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	a.ID = strings.TrimPrefix(a.ID, string(AgreementDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &a.Doc, a.RequesterOrganizationID); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+//Returns Agreement with the given ID
+func (s *SmartContract) GetAgreement(ctx contractapi.TransactionContextInterface, id string) (*Agreement, error) {
+	a, err := s.GetAgreementInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.FromAgreementInner(ctx, a), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+//Accepting a private offer stores it as ACCEPTED and opens an agreement whose value both organizations can read
+func TestAcceptPrivateOffer(t *testing.T) {
+	owner := newTestIdentity(t, "Org1MSP", "user1", testAttributes(false))
+	counterparty := newTestIdentity(t, "Org2MSP", "user2", testAttributes(false))
+	other := newTestIdentity(t, "Org3MSP", "user3", testAttributes(false))
+	admin := newTestIdentity(t, "Org4MSP", "admin", testAttributes(true))
+
+	f := newOwnershipFixture(t, owner, counterparty, other, admin)
+	s := f.contract
+
+	ctx := f.as(counterparty)
+	f.setTransientPrice()
+	f.must(s.MakePrivateOffer(ctx, "pof1", "org2", "r1", 0))
+	f.must(s.AcceptOffer(f.as(owner), "r1", "pof1", true))
+
+	offerBytes, err := f.stub.GetState(s.GetOfferID(nil, "pof1"))
+	f.must(err)
+
+	var offer OfferInner
+	f.must(json.Unmarshal(offerBytes, &offer))
+	if offer.Status != OfferStatusAccepted {
+		t.Fatalf("expected the offer to be stored as %s, got %s", OfferStatusAccepted, offer.Status)
+	}
+
+	agreement, err := s.GetAgreementInner(f.as(owner), "r1")
+	f.must(err)
+	if !agreement.PrivateValue || agreement.ValueHash != offer.ValueHash || agreement.Value != (Price{}) {
+		t.Fatalf("expected the agreement to keep the private value of the offer, got %+v", agreement)
+	}
+
+	want := Price{Amount: 100, Exponent: 2, Currency: "EUR"}
+	for _, identity := range [][]byte{owner, counterparty} {
+		price, err := s.GetAgreementPrice(f.as(identity), "r1")
+		f.must(err)
+		if *price != want {
+			t.Fatalf("expected %+v, got %+v", want, *price)
+		}
+	}
+
+	if _, err := s.GetAgreementPrice(f.as(other), "r1"); testErrorCode(err) != ErrForbidden {
+		t.Fatalf("expected %s, got %v", ErrForbidden, err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Summary of an archived document
+type ArchivedDoc struct {
+	ID         string    `json:"id"`
+	Type       DocType   `json:"doc_type"`
+	ArchivedAt time.Time `json:"archived_at"`
+	ArchivedBy string    `json:"archived_by"`
+}
+
+//Marks the document as archived by the given user at the given time
+//Fails if the document is already archived
+func archiveDoc(d *Doc, clientID string, now time.Time) error {
+	if d.Archived {
+		return newError(ErrInvalidState, "%s is already archived", d.Type)
+	}
+
+	d.Archived = true
+	d.ArchivedAt = now
+	d.ArchivedBy = clientID
+	d.UpdatedBy = clientID
+
+	return nil
+}
+
+//Clears the archived state of the document
+//Fails if the document is not archived
+func restoreDoc(d *Doc, clientID string) error {
+	if !d.Archived {
+		return newError(ErrInvalidState, "%s is not archived", d.Type)
+	}
+
+	d.Archived = false
+	d.ArchivedAt = time.Time{}
+	d.ArchivedBy = ""
+	d.UpdatedBy = clientID
+
+	return nil
+}
+
+//Returns all the archived documents of the given type
+func (s *SmartContract) GetAllArchived(ctx contractapi.TransactionContextInterface, docTypeInput string) ([]*ArchivedDoc, error) {
+	docType := DocType(docTypeInput)
+	att, ok := docReadAttributes[docType]
+	if !ok {
+		return nil, newError(ErrInvalidArgument, "invalid doc type")
+	}
+
+	if err := s.HasPermission(ctx, att); err != nil {
+		return nil, err
+	}
+
+	query, err := archivedDocsQuery(docType)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var assets []*ArchivedDoc
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var d struct {
+			Doc
+			ID string `json:"id"`
+		}
+		err = json.Unmarshal(queryResult.Value, &d)
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, &ArchivedDoc{
+			ID:         strings.TrimPrefix(d.ID, string(docType)+"_"),
+			Type:       d.Type,
+			ArchivedAt: d.ArchivedAt,
+			ArchivedBy: d.ArchivedBy,
+		})
+	}
+
+	return assets, nil
+}
+
+//Checks whether the document stored under the given key is archived
+//Records can't be created against archived documents
+func isArchived(ctx contractapi.TransactionContextInterface, key string) (bool, error) {
+	assetBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
+	}
+
+	if assetBytes == nil {
+		return false, nil
+	}
+
+	var d Doc
+	err = json.Unmarshal(assetBytes, &d)
+	if err != nil {
+		return false, err
+	}
+
+	return d.Archived, nil
+}
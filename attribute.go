@@ -1,13 +1,12 @@
 package main
 
 import (
-	"fmt"
-
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 //Attributes used for access control
-//Must be issued when creating user CA
+//Issued in the certificate of the user when creating user CA, or granted through a role stored on the ledger
+//roles.admin and admin can only be issued in the certificate
 const (
 	UnitsCreate Attribute = "units.create"
 	UnitsRead   Attribute = "units.read"
@@ -43,24 +42,90 @@ const (
 	OffersRead   Attribute = "offers.read"
 	OffersUpdate Attribute = "offers.update"
 	OffersDelete Attribute = "offers.delete"
+
+	RolesRead  Attribute = "roles.read"
+	RolesAdmin Attribute = "roles.admin"
+
+	//Lets the user change documents owned by any organization
+	Admin Attribute = "admin"
 )
 
 type Attribute string
 
+//Every attribute that can be bundled in a role
+//Attributes letting a user grant roles or act for every organization are left out, so a role holder can't escalate its own permissions
+var attributes = []Attribute{
+	UnitsCreate, UnitsRead, UnitsUpdate, UnitsDelete,
+	ProductsCreate, ProductsRead, ProductsUpdate, ProductsDelete,
+	OrganizationsCreate, OrganizationsRead, OrganizationsUpdate, OrganizationsDelete,
+	OrdersCreate, OrdersRead, OrdersUpdate, OrdersDelete,
+	TransactionsCreate, TransactionsRead, TransactionsUpdate, TransactionsDelete,
+	RequestsCreate, RequestsRead, RequestsUpdate, RequestsDelete,
+	OffersCreate, OffersRead, OffersUpdate, OffersDelete,
+	RolesRead,
+}
+
+//Returns whether the attribute can be granted by a role
+func isRoleAttribute(att Attribute) bool {
+	for _, a := range attributes {
+		if a == att {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Attribute required to read the documents of each type
+var docReadAttributes = map[DocType]Attribute{
+	UnitDoc:         UnitsRead,
+	ProductDoc:      ProductsRead,
+	OrganizationDoc: OrganizationsRead,
+	OrderDoc:        OrdersRead,
+	TransactionDoc:  TransactionsRead,
+	RequestDoc:      RequestsRead,
+	OfferDoc:        OffersRead,
+	AgreementDoc:    TransactionsRead,
+}
+
 func (a Attribute) String() string {
 	return string(a)
 }
 
+//Returns the names of every attribute, for validation
+func attributeNames() []string {
+	names := make([]string, 0, len(attributes))
+	for _, a := range attributes {
+		names = append(names, a.String())
+	}
+
+	return names
+}
+
 //Main function to check whether the current user has the required permissions to run the command
+//The attribute is granted either by the certificate of the user or by a role assigned on the ledger to the user or to its MSP
+//Roles are not consulted for attributes that can only be issued in the certificate, even if a role stored earlier lists them
 //Returns "not authorized" if the user does not have the required permissions
 func (s *SmartContract) HasPermission(ctx contractapi.TransactionContextInterface, att Attribute) error {
 	if !s.checkPermissions {
 		return nil
 	}
 
-	err := ctx.GetClientIdentity().AssertAttributeValue(att.String(), "true")
+	if err := ctx.GetClientIdentity().AssertAttributeValue(att.String(), "true"); err == nil {
+		return nil
+	}
+
+	if !isRoleAttribute(att) {
+		return newError(ErrForbidden, "not authorized, missing attribute %s", att)
+	}
+
+	granted, err := s.hasRoleAttribute(ctx, att)
 	if err != nil {
-		return fmt.Errorf(" not authorized ")
+		return err
+	}
+
+	if !granted {
+		return newError(ErrForbidden, "not authorized, missing attribute %s", att)
 	}
 
 	return nil
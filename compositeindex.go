@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	QueryConfigKey = "config_query"
+)
+
+//Settings of the list queries, read from the ledger so every peer answers them the same way
+//When CompositeIndexes is set, order and transaction list queries and the reference checks of deletes are answered from composite key indexes instead of CouchDB rich queries
+//This lets the contract run on peers using LevelDB, where Search, time range queries and the other list queries are not available
+type QueryConfig struct {
+	CompositeIndexes bool `json:"composite_indexes"`
+}
+
+//Object types of the composite keys used as secondary indexes
+//They let list queries run without CouchDB rich queries, so the contract also works on peers running LevelDB
+//ReferenceIndex lists every document under its type, a referencing field and the ID stored in it, see reference
+const (
+	OrderOrgStatusIndex         = "order~org~status~id"
+	OrderStatusIndex            = "order~status~id"
+	OrderProductUnitStatusIndex = "order~product~unit~status~id"
+	TransactionOrderIndex       = "transaction~order~id"
+	OrganizationMSPIndex        = "organization~msp~id"
+	ReferenceIndex              = "reference~doctype~field~target~id"
+)
+
+//Every status an order can have, used to clear the index entries of the previous status
+var orderStatuses = []OrderStatus{OrderStatusOpen, OrderStatusPartiallyFilled, OrderStatusClosed}
+
+//Value stored under index keys, only the key matters
+var indexValue = []byte{0x00}
+
+//Sets whether order and transaction list queries and reference checks are answered from composite key indexes
+//Run MigrateCompositeIndexes first on ledgers holding documents written before the indexes were kept
+func (s *SmartContract) SetCompositeIndexes(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return err
+	}
+
+	configBytes, err := json.Marshal(QueryConfig{CompositeIndexes: enabled})
+	if err != nil {
+		return newError(ErrInternal, "failed to encode query config: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(QueryConfigKey, configBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to store query config: %v", err)
+	}
+
+	return nil
+}
+
+//Returns the settings of the list queries
+func (s *SmartContract) GetQueryConfig(ctx contractapi.TransactionContextInterface) (*QueryConfig, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	return s.getQueryConfig(ctx)
+}
+
+func (s *SmartContract) getQueryConfig(ctx contractapi.TransactionContextInterface) (*QueryConfig, error) {
+	configBytes, err := ctx.GetStub().GetState(QueryConfigKey)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to read query config: %v", err)
+	}
+
+	var config QueryConfig
+	if configBytes == nil {
+		return &config, nil
+	}
+
+	err = json.Unmarshal(configBytes, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+//Returns whether list queries are answered from composite key indexes
+func (s *SmartContract) useCompositeIndexes(ctx contractapi.TransactionContextInterface) (bool, error) {
+	config, err := s.getQueryConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return config.CompositeIndexes, nil
+}
+
+//Stores or removes the index entry with the given attributes
+func (s *SmartContract) setIndexEntry(ctx contractapi.TransactionContextInterface, index string, attributes []string, present bool) error {
+	key, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+	if err != nil {
+		return newError(ErrInternal, "failed to create index key: %v", err)
+	}
+
+	if present {
+		err = ctx.GetStub().PutState(key, indexValue)
+	} else {
+		err = ctx.GetStub().DelState(key)
+	}
+	if err != nil {
+		return newError(ErrInternal, "failed to update index %s: %v", index, err)
+	}
+
+	return nil
+}
+
+//Keeps the order indexes in line with the given order
+//Only the entry of the current status is kept, archived orders are left out of the indexes
+//Entries of the other statuses are removed without reading the previous state, which is not visible when the order was already written in the same transaction
+func (s *SmartContract) putOrderIndexes(ctx contractapi.TransactionContextInterface, order *OrderInner) error {
+	for _, status := range orderStatuses {
+		present := status == order.Status && !order.Archived
+
+		if err := s.setIndexEntry(ctx, OrderOrgStatusIndex, []string{order.OrganizationID, string(status), order.ID}, present); err != nil {
+			return err
+		}
+
+		if err := s.setIndexEntry(ctx, OrderStatusIndex, []string{string(status), order.ID}, present); err != nil {
+			return err
+		}
+
+		if err := s.setIndexEntry(ctx, OrderProductUnitStatusIndex, []string{order.ProductID, order.UnitID, string(status), order.ID}, present); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Keeps the transaction index in line with the given transaction
+//The transaction is indexed under its order and, for matched transactions, under the buying order too
+func (s *SmartContract) putTransactionIndexes(ctx contractapi.TransactionContextInterface, transaction *TransactionInner) error {
+	if err := s.setIndexEntry(ctx, TransactionOrderIndex, []string{transaction.OrderID, transaction.ID}, !transaction.Archived); err != nil {
+		return err
+	}
+
+	if transaction.MatchedOrderID == "" {
+		return nil
+	}
+
+	return s.setIndexEntry(ctx, TransactionOrderIndex, []string{transaction.MatchedOrderID, transaction.ID}, !transaction.Archived)
+}
+
+//Returns the IDs stored as the last attribute of the index entries matching the given leading attributes
+func (s *SmartContract) getIndexedIDs(ctx contractapi.TransactionContextInterface, index string, attributes []string) ([]string, error) {
+	results, err := ctx.GetStub().GetStateByPartialCompositeKey(index, attributes)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var ids []string
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyAttributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, keyAttributes[len(keyAttributes)-1])
+	}
+
+	return ids, nil
+}
+
+//Returns one page of the IDs stored as the last attribute of the index entries matching the given leading attributes
+func (s *SmartContract) getIndexedIDsPage(ctx contractapi.TransactionContextInterface, index string, attributes []string, pageSize int32, bookmark string) ([]string, PageInfo, error) {
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		return nil, PageInfo{}, newError(ErrInvalidArgument, "invalid page size %d, it must be between 1 and %d", pageSize, MaxPageSize)
+	}
+
+	results, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, attributes, pageSize, bookmark)
+	if err != nil {
+		return nil, PageInfo{}, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var ids []string
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+
+		_, keyAttributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+
+		ids = append(ids, keyAttributes[len(keyAttributes)-1])
+	}
+
+	info := PageInfo{
+		FetchedCount: metadata.GetFetchedRecordsCount(),
+		Bookmark:     metadata.GetBookmark(),
+	}
+
+	if info.FetchedCount < pageSize {
+		info.Bookmark = ""
+	}
+
+	return ids, info, nil
+}
+
+//Returns the orders listed in the given index under the given leading attributes
+func (s *SmartContract) getIndexedOrders(ctx contractapi.TransactionContextInterface, index string, attributes []string) ([]*Order, error) {
+	ids, err := s.getIndexedIDs(ctx, index, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []*Order
+	for _, id := range ids {
+		order, err := s.GetOrderInner(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, s.FromOrderInner(ctx, order))
+	}
+
+	return assets, nil
+}
+
+//Returns one page of the orders listed in the given index under the given leading attributes
+func (s *SmartContract) getIndexedOrdersPage(ctx contractapi.TransactionContextInterface, index string, attributes []string, pageSize int32, bookmark string) (*OrdersPage, error) {
+	ids, info, err := s.getIndexedIDsPage(ctx, index, attributes, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrdersPage{PageInfo: info, Records: []*Order{}}
+	for _, id := range ids {
+		order, err := s.GetOrderInner(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Records = append(page.Records, s.FromOrderInner(ctx, order))
+	}
+
+	return page, nil
+}
+
+//Returns the transactions of the order with the given ID from the transaction index
+func (s *SmartContract) getIndexedTransactionsInner(ctx contractapi.TransactionContextInterface, orderID string) ([]*TransactionInner, error) {
+	ids, err := s.getIndexedIDs(ctx, TransactionOrderIndex, []string{orderID})
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []*TransactionInner
+	for _, id := range ids {
+		transaction, err := s.GetTransactionInner(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, transaction)
+	}
+
+	return assets, nil
+}
+
+//Returns the open and partially filled orders of the product and unit with the given IDs from the order index
+func (s *SmartContract) getIndexedOpenOrdersInner(ctx contractapi.TransactionContextInterface, productID string, unitID string) ([]*OrderInner, error) {
+	var assets []*OrderInner
+	for _, status := range []OrderStatus{OrderStatusOpen, OrderStatusPartiallyFilled} {
+		ids, err := s.getIndexedIDs(ctx, OrderProductUnitStatusIndex, []string{productID, unitID, string(status)})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			order, err := s.GetOrderInner(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+
+			assets = append(assets, order)
+		}
+	}
+
+	return assets, nil
+}
+
+//Returns the transactions of the order with the given ID from the transaction index
+func (s *SmartContract) getIndexedTransactions(ctx contractapi.TransactionContextInterface, orderID string) ([]*Transaction, error) {
+	transactions, err := s.getIndexedTransactionsInner(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []*Transaction
+	for _, transaction := range transactions {
+		assets = append(assets, s.FromTransactionInner(ctx, transaction))
+	}
+
+	return assets, nil
+}
+
+//Returns one page of the transactions of the order with the given ID from the transaction index
+func (s *SmartContract) getIndexedTransactionsPage(ctx contractapi.TransactionContextInterface, orderID string, pageSize int32, bookmark string) (*TransactionsPage, error) {
+	ids, info, err := s.getIndexedIDsPage(ctx, TransactionOrderIndex, []string{orderID}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionsPage{PageInfo: info, Records: []*Transaction{}}
+	for _, id := range ids {
+		transaction, err := s.GetTransactionInner(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Records = append(page.Records, s.FromTransactionInner(ctx, transaction))
+	}
+
+	return page, nil
+}
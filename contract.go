@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
-	"fmt"
+	"encoding/hex"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -16,12 +18,12 @@ type SmartContract struct {
 func (s *SmartContract) GetSubmittingClientIdentity(ctx contractapi.TransactionContextInterface) (string, error) {
 	b64ID, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
-		return " ", fmt.Errorf("failed to read clientID : % v", err)
+		return " ", newError(ErrInternal, "failed to read clientID : % v", err)
 	}
 
 	decodeID, err := base64.StdEncoding.DecodeString(b64ID)
 	if err != nil {
-		return " ", fmt.Errorf("failed to base64 decode clientID : % v", err)
+		return " ", newError(ErrInternal, "failed to base64 decode clientID : % v", err)
 	}
 
 	return string(decodeID), nil
@@ -31,3 +33,25 @@ func (s *SmartContract) GetSubmittingClientIdentity(ctx contractapi.TransactionC
 func (s *SmartContract) GetSubmittingClientOrganization(ctx contractapi.TransactionContextInterface) (string, error) {
 	return ctx.GetClientIdentity().GetMSPID()
 }
+
+//Returns the hex encoded SHA-256 fingerprint of the certificate of the current user
+func getClientCertFingerprint(ctx contractapi.TransactionContextInterface) (string, error) {
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil || cert == nil {
+		return "", newError(ErrInternal, "failed to read client certificate: %v", err)
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+//Returns the timestamp of the current transaction
+//The same value is seen by every endorsing peer, so it is safe to store on the ledger
+func (s *SmartContract) GetTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, newError(ErrInternal, "failed to read transaction timestamp: %v", err)
+	}
+
+	return time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC(), nil
+}
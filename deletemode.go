@@ -0,0 +1,26 @@
+package main
+
+const (
+	//Archives the record, refusing if it is still referenced
+	DeleteModeRestrict DeleteMode = "RESTRICT"
+	//Archives the record and closes the open records of the current organization that depend on it
+	DeleteModeArchive DeleteMode = "ARCHIVE"
+)
+
+type DeleteMode string
+
+func (d DeleteMode) String() string {
+	return string(d)
+}
+
+//Parses the delete mode, refusing to delete referenced records by default
+func ParseDeleteMode(mode string) (DeleteMode, error) {
+	switch mode {
+	case "", "RESTRICT":
+		return DeleteModeRestrict, nil
+	case "ARCHIVE":
+		return DeleteModeArchive, nil
+	}
+
+	return "", newError(ErrInvalidArgument, "invalid delete mode")
+}
@@ -1,13 +1,91 @@
 package main
 
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
 type DocType string
 
+//Fixed width layout of the times stored for queries, so comparing them as text orders them in time
+const SortableTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
 //Helper structure for couchDB
 //DocType represents the document type - making it easier to search
+//Owner stores the MSP ID of the organization that owns the document
 //CreatedBy stores the ID of the user that created the document
 //UpdatedBy stores the ID of the user that updated the document
+//CreatedAt and UpdatedAt store the timestamps of the transactions that created and last updated the document
+//CreatedAtUTC and UpdatedAtUTC store the same timestamps in the fixed width SortableTimeLayout, queries filter and sort on them
+//Archived, ArchivedAt and ArchivedBy are set when the document is archived instead of deleted
 type Doc struct {
-	Type      DocType `json:"doc_type"`
-	CreatedBy string  `json:"created_by"`
-	UpdatedBy string  `json:"updated_by"`
+	Type         DocType   `json:"doc_type"`
+	Owner        string    `json:"owner"`
+	CreatedBy    string    `json:"created_by"`
+	UpdatedBy    string    `json:"updated_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	CreatedAtUTC string    `json:"created_at_utc"`
+	UpdatedAtUTC string    `json:"updated_at_utc"`
+	Archived     bool      `json:"archived,omitempty"`
+	ArchivedAt   time.Time `json:"archived_at"`
+	ArchivedBy   string    `json:"archived_by,omitempty"`
+}
+
+//Builds the Doc of a new document of the given type created by the given user
+//Both timestamps are set from the transaction timestamp so every peer stores the same value
+//The document is owned by the MSP of the user, callers creating a document on behalf of another organization set Owner afterwards
+func (s *SmartContract) newDoc(ctx contractapi.TransactionContextInterface, docType DocType, clientID string) (Doc, error) {
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return Doc{}, err
+	}
+
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return Doc{}, newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	return Doc{
+		Type:         docType,
+		Owner:        mspID,
+		CreatedBy:    clientID,
+		UpdatedBy:    clientID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		CreatedAtUTC: sortableTime(now),
+		UpdatedAtUTC: sortableTime(now),
+	}, nil
+}
+
+//Stamps the document with the timestamp of the current transaction and the ID of the user submitting it
+//Called by every put helper, so UpdatedBy and the history of the document always name the user behind each change
+func (s *SmartContract) touchDoc(ctx contractapi.TransactionContextInterface, d *Doc) error {
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.UpdatedAt = now
+	d.UpdatedAtUTC = sortableTime(now)
+	d.UpdatedBy = clientID
+
+	return nil
+}
+
+//Returns the time in UTC formatted with SortableTimeLayout, the form stored times are queried and sorted on
+//CouchDB compares the RFC 3339 strings of time.Time as text, which misorders times whose fractional seconds have a different number of digits
+//A zero time is returned as an empty string
+func sortableTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(SortableTimeLayout)
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Endorsement policy set on a single key
+//When Orgs is empty the key follows the chaincode endorsement policy
+type KeyEndorsementPolicy struct {
+	Key  string   `json:"key"`
+	Orgs []string `json:"orgs"`
+}
+
+//Requires a peer of every given MSP to endorse later changes to the key
+//Set when orders, offers and transactions are created, so an organization can't change them without its counterparties
+//The creation itself is still validated against the chaincode endorsement policy
+func (s *SmartContract) setKeyEndorsement(ctx contractapi.TransactionContextInterface, key string, mspIDs ...string) error {
+	var orgs []string
+	seen := make(map[string]bool)
+	for _, mspID := range mspIDs {
+		if mspID == "" || seen[mspID] {
+			continue
+		}
+
+		seen[mspID] = true
+		orgs = append(orgs, mspID)
+	}
+
+	if len(orgs) == 0 {
+		return nil
+	}
+
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return newError(ErrInternal, "failed to create endorsement policy: %v", err)
+	}
+
+	if err := ep.AddOrgs(statebased.RoleTypePeer, orgs...); err != nil {
+		return newError(ErrInternal, "failed to create endorsement policy: %v", err)
+	}
+
+	policy, err := ep.Policy()
+	if err != nil {
+		return newError(ErrInternal, "failed to create endorsement policy: %v", err)
+	}
+
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return newError(ErrInternal, "failed to set endorsement policy of %s: %v", key, err)
+	}
+
+	return nil
+}
+
+//Returns the MSPs that must endorse changes to the document of the given type with the given ID
+//User inputs the doctype (order, offer, transaction, ...) and the ID of the document
+func (s *SmartContract) GetKeyEndorsementPolicy(ctx contractapi.TransactionContextInterface, docTypeInput string, id string) (*KeyEndorsementPolicy, error) {
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return nil, err
+	}
+
+	docType := DocType(docTypeInput)
+	if _, ok := docReadAttributes[docType]; !ok {
+		return nil, newError(ErrInvalidArgument, "invalid doctype %s", docTypeInput)
+	}
+
+	key := string(docType) + "_" + id
+
+	policy, err := ctx.GetStub().GetStateValidationParameter(key)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to read endorsement policy of %s: %v", key, err)
+	}
+
+	result := &KeyEndorsementPolicy{Key: key, Orgs: []string{}}
+	if len(policy) == 0 {
+		return result, nil
+	}
+
+	ep, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to parse endorsement policy of %s: %v", key, err)
+	}
+
+	result.Orgs = ep.ListOrgs()
+	sort.Strings(result.Orgs)
+
+	return result, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//Stable codes returned in every error of the contract
+//Clients should rely on the code and not on the message
+const (
+	ErrNotFound        ErrorCode = "NOT_FOUND"
+	ErrAlreadyExists   ErrorCode = "ALREADY_EXISTS"
+	ErrForbidden       ErrorCode = "FORBIDDEN"
+	ErrInvalidArgument ErrorCode = "INVALID_ARGUMENT"
+	ErrInvalidState    ErrorCode = "INVALID_STATE"
+	ErrConflict        ErrorCode = "CONFLICT"
+	//Reading or writing the ledger failed, retrying may succeed
+	ErrInternal ErrorCode = "INTERNAL"
+)
+
+type ErrorCode string
+
+func (c ErrorCode) String() string {
+	return string(c)
+}
+
+//Error returned by the contract
+//Serialized as JSON in the error message, e.g. {"code":"NOT_FOUND","message":"order o1 does not exist"}
+//Violations lists every invalid argument when the input fails validation
+//Details holds structured data about the failure for the errors that have any, such as TransactionTransitionDetails
+type ContractError struct {
+	Code       ErrorCode   `json:"code"`
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations,omitempty"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+func (e *ContractError) Error() string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return e.Code.String()
+	}
+
+	return string(b)
+}
+
+//Builds a ContractError with the given code and a formatted message
+func newError(code ErrorCode, format string, args ...interface{}) error {
+	return &ContractError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
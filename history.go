@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Metadata of one version of a document, read from the history of its key
+//TxID and Timestamp identify the Fabric transaction that wrote the version
+//ClientID is copied from the UpdatedBy field of the stored document, not read from the transaction creator
+//It is empty for deletes and for versions written without UpdatedBy, and only names the submitter if the writer set it
+//IsDelete is set when the transaction removed the key, in which case there is no value
+type HistoryEntry struct {
+	TxID      string    `json:"tx_id"`
+	Timestamp time.Time `json:"timestamp"`
+	ClientID  string    `json:"client_id"`
+	IsDelete  bool      `json:"is_delete"`
+}
+
+type UnitHistory struct {
+	HistoryEntry
+	Value *Unit `json:"value,omitempty"`
+}
+
+type ProductHistory struct {
+	HistoryEntry
+	Value *Product `json:"value,omitempty"`
+}
+
+type OrganizationHistory struct {
+	HistoryEntry
+	Value *Organization `json:"value,omitempty"`
+}
+
+type OrderHistory struct {
+	HistoryEntry
+	Value *Order `json:"value,omitempty"`
+}
+
+type TransactionHistory struct {
+	HistoryEntry
+	Value *Transaction `json:"value,omitempty"`
+}
+
+type RequestHistory struct {
+	HistoryEntry
+	Value *Request `json:"value,omitempty"`
+}
+
+type OfferHistory struct {
+	HistoryEntry
+	Value *Offer `json:"value,omitempty"`
+}
+
+type AgreementHistory struct {
+	HistoryEntry
+	Value *Agreement `json:"value,omitempty"`
+}
+
+//Walks the history of the given key from the oldest to the newest version
+//Calls fn with the metadata of every version and its value, which is nil for deletes
+func (s *SmartContract) getHistory(ctx contractapi.TransactionContextInterface, key string, fn func(entry HistoryEntry, value []byte) error) error {
+	results, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return newError(ErrInternal, "failed to get history for %s: %v", key, err)
+	}
+	defer results.Close()
+
+	var versions []HistoryEntry
+	var values [][]byte
+	for results.HasNext() {
+		km, err := results.Next()
+		if err != nil {
+			return err
+		}
+
+		entry := HistoryEntry{
+			TxID:     km.GetTxId(),
+			IsDelete: km.GetIsDelete(),
+		}
+
+		if ts := km.GetTimestamp(); ts != nil {
+			entry.Timestamp = time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+		}
+
+		var value []byte
+		if !entry.IsDelete {
+			value = km.GetValue()
+
+			var d Doc
+			err = json.Unmarshal(value, &d)
+			if err != nil {
+				return err
+			}
+
+			//The history API does not return the creator of the transaction, so the best available value is
+			//the UpdatedBy the contract stored with the version
+			entry.ClientID = d.UpdatedBy
+		}
+
+		versions = append(versions, entry)
+		values = append(values, value)
+	}
+
+	//The peer returns the newest version first
+	for i := len(versions) - 1; i >= 0; i-- {
+		if err := fn(versions[i], values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Returns every version of the unit with the given ID, oldest first
+func (s *SmartContract) GetUnitHistory(ctx contractapi.TransactionContextInterface, id string) ([]*UnitHistory, error) {
+	if err := s.HasPermission(ctx, UnitsRead); err != nil {
+		return nil, err
+	}
+
+	var history []*UnitHistory
+	err := s.getHistory(ctx, s.GetUnitID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &UnitHistory{HistoryEntry: entry}
+		if value != nil {
+			var unit UnitInner
+			if err := json.Unmarshal(value, &unit); err != nil {
+				return err
+			}
+
+			unit.ID = strings.TrimPrefix(unit.ID, string(UnitDoc)+"_")
+			h.Value = FromUnitInner(&unit)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the product with the given ID, oldest first
+func (s *SmartContract) GetProductHistory(ctx contractapi.TransactionContextInterface, id string) ([]*ProductHistory, error) {
+	if err := s.HasPermission(ctx, ProductsRead); err != nil {
+		return nil, err
+	}
+
+	var history []*ProductHistory
+	err := s.getHistory(ctx, s.GetProductID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &ProductHistory{HistoryEntry: entry}
+		if value != nil {
+			var product ProductInner
+			if err := json.Unmarshal(value, &product); err != nil {
+				return err
+			}
+
+			product.ID = strings.TrimPrefix(product.ID, string(ProductDoc)+"_")
+			h.Value = s.FromProductInner(ctx, &product)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the organization with the given ID, oldest first
+func (s *SmartContract) GetOrganizationHistory(ctx contractapi.TransactionContextInterface, id string) ([]*OrganizationHistory, error) {
+	if err := s.HasPermission(ctx, OrganizationsRead); err != nil {
+		return nil, err
+	}
+
+	var history []*OrganizationHistory
+	err := s.getHistory(ctx, s.GetOrganizationID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &OrganizationHistory{HistoryEntry: entry}
+		if value != nil {
+			var organization OrganizationInner
+			if err := json.Unmarshal(value, &organization); err != nil {
+				return err
+			}
+
+			organization.ID = strings.TrimPrefix(organization.ID, string(OrganizationDoc)+"_")
+			h.Value = s.FromOrganizationInner(ctx, &organization)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the order with the given ID, oldest first
+//The organization, product and unit of each version are resolved from their current state
+func (s *SmartContract) GetOrderHistory(ctx contractapi.TransactionContextInterface, id string) ([]*OrderHistory, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	var history []*OrderHistory
+	err := s.getHistory(ctx, s.GetOrderID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &OrderHistory{HistoryEntry: entry}
+		if value != nil {
+			var order OrderInner
+			if err := json.Unmarshal(value, &order); err != nil {
+				return err
+			}
+
+			order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+			h.Value = s.FromOrderInner(ctx, &order)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the transaction with the given ID, oldest first
+//Shows who moved the transaction to each status and when
+func (s *SmartContract) GetTransactionHistory(ctx contractapi.TransactionContextInterface, id string) ([]*TransactionHistory, error) {
+	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
+		return nil, err
+	}
+
+	var history []*TransactionHistory
+	err := s.getHistory(ctx, s.GetTransactionID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &TransactionHistory{HistoryEntry: entry}
+		if value != nil {
+			var transaction TransactionInner
+			if err := json.Unmarshal(value, &transaction); err != nil {
+				return err
+			}
+
+			transaction.ID = strings.TrimPrefix(transaction.ID, string(TransactionDoc)+"_")
+			h.Value = s.FromTransactionInner(ctx, &transaction)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the request with the given ID, oldest first
+func (s *SmartContract) GetRequestHistory(ctx contractapi.TransactionContextInterface, id string) ([]*RequestHistory, error) {
+	if err := s.HasPermission(ctx, RequestsRead); err != nil {
+		return nil, err
+	}
+
+	var history []*RequestHistory
+	err := s.getHistory(ctx, s.GetRequestID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &RequestHistory{HistoryEntry: entry}
+		if value != nil {
+			var request RequestInner
+			if err := json.Unmarshal(value, &request); err != nil {
+				return err
+			}
+
+			request.ID = strings.TrimPrefix(request.ID, string(RequestDoc)+"_")
+			h.Value = s.FromRequestInner(ctx, &request)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the offer with the given ID, oldest first
+func (s *SmartContract) GetOfferHistory(ctx contractapi.TransactionContextInterface, id string) ([]*OfferHistory, error) {
+	if err := s.HasPermission(ctx, OffersRead); err != nil {
+		return nil, err
+	}
+
+	var history []*OfferHistory
+	err := s.getHistory(ctx, s.GetOfferID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &OfferHistory{HistoryEntry: entry}
+		if value != nil {
+			var offer OfferInner
+			if err := json.Unmarshal(value, &offer); err != nil {
+				return err
+			}
+
+			offer.ID = strings.TrimPrefix(offer.ID, string(OfferDoc)+"_")
+			h.Value = s.FromOfferInner(ctx, &offer)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
+
+//Returns every version of the agreement with the given ID, oldest first
+func (s *SmartContract) GetAgreementHistory(ctx contractapi.TransactionContextInterface, id string) ([]*AgreementHistory, error) {
+	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
+		return nil, err
+	}
+
+	var history []*AgreementHistory
+	err := s.getHistory(ctx, s.GetAgreementID(ctx, id), func(entry HistoryEntry, value []byte) error {
+		h := &AgreementHistory{HistoryEntry: entry}
+		if value != nil {
+			var agreement AgreementInner
+			if err := json.Unmarshal(value, &agreement); err != nil {
+				return err
+			}
+
+			agreement.ID = strings.TrimPrefix(agreement.ID, string(AgreementDoc)+"_")
+			h.Value = s.FromAgreementInner(ctx, &agreement)
+		}
+
+		history = append(history, h)
+		return nil
+	})
+
+	return history, err
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	OrdersMatchedEventKey = "orders_matched"
+)
+
+type OrdersMatchedEvent struct {
+	ProductID      string   `json:"product_id"`
+	UnitID         string   `json:"unit_id"`
+	TransactionIDs []string `json:"transaction_ids"`
+}
+
+func NewOrdersMatchedEvent(productID string, unitID string, transactionIDs []string) ([]byte, error) {
+	return json.Marshal(OrdersMatchedEvent{ProductID: productID, UnitID: unitID, TransactionIDs: transactionIDs})
+}
+
+//Sorts orders by price-time priority
+//BUY orders with the highest price come first, SELL orders with the lowest price come first
+//Orders with the same price are sorted from the oldest to the newest
+func sortOrdersByPriority(orders []*OrderInner) {
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if a.Price.Currency != b.Price.Currency {
+			return a.Price.Currency < b.Price.Currency
+		}
+
+		cmp, _ := a.Price.Cmp(b.Price)
+		if cmp != 0 {
+			if a.Type == OrderTypeBuy {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+
+		return a.ID < b.ID
+	})
+}
+
+//Returns all open and partially filled OrderInner for the product and unit with the given IDs
+func (s *SmartContract) getOpenOrdersInner(ctx contractapi.TransactionContextInterface, productID string, unitID string) ([]*OrderInner, error) {
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOpenOrdersInner(ctx, productID, unitID)
+	}
+
+	query, err := openOrdersQuery(productID, unitID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var assets []*OrderInner
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+		var o OrderInner
+		err = json.Unmarshal(queryResult.Value, &o)
+		if err != nil {
+			return nil, err
+		}
+
+		o.ID = strings.TrimPrefix(o.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &o.Doc, o.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, &o)
+	}
+
+	return assets, nil
+}
+
+//Matches the open BUY orders against the open SELL orders for the product and unit with the given IDs
+//Orders are paired using price-time priority and a BUY order only matches SELL orders with the same currency and a price lower or equal to its own
+//Orders with a private price are not matched, as their price is not readable by every peer
+//Every transaction created is owned by the MSP of the BUY order
+//Any user allowed to update orders can run the matching, the key endorsement policies of the matched orders make the result valid only once their organizations endorse it
+//A transaction is created on the SELL order for every match and the filled quantity of both orders is updated
+//Returns the transactions created
+func (s *SmartContract) MatchOrders(ctx contractapi.TransactionContextInterface, productID string, unitID string) ([]*Transaction, error) {
+	if err := s.HasPermission(ctx, OrdersUpdate); err != nil {
+		return nil, err
+	}
+
+	if err := s.HasPermission(ctx, TransactionsCreate); err != nil {
+		return nil, err
+	}
+
+	orders, err := s.getOpenOrdersInner(ctx, productID, unitID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buys, sells []*OrderInner
+	for _, order := range orders {
+		if order.PrivatePrice {
+			continue
+		}
+
+		switch order.Type {
+		case OrderTypeBuy:
+			buys = append(buys, order)
+		case OrderTypeSell:
+			sells = append(sells, order)
+		}
+	}
+
+	sortOrdersByPriority(buys)
+	sortOrdersByPriority(sells)
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.newDoc(ctx, TransactionDoc, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []*Transaction
+	var createdIDs []string
+	touched := make(map[string]bool)
+	for _, buy := range buys {
+		for _, sell := range sells {
+			if buy.Remaining == 0 {
+				break
+			}
+
+			if sell.Remaining == 0 ||
+				sell.OrganizationID == buy.OrganizationID ||
+				sell.Price.Currency != buy.Price.Currency {
+				continue
+			}
+
+			if cmp, _ := buy.Price.Cmp(sell.Price); cmp < 0 {
+				continue
+			}
+
+			amount := buy.Remaining
+			if sell.Remaining < amount {
+				amount = sell.Remaining
+			}
+
+			id := fmt.Sprintf("%s-%d", ctx.GetStub().GetTxID(), len(created))
+			transaction := TransactionInner{
+				Doc:            doc,
+				ID:             id,
+				Amount:         amount,
+				Description:    fmt.Sprintf("matched order %s with order %s", buy.ID, sell.ID),
+				Status:         TransactionStatusOpen,
+				OrganizationID: buy.OrganizationID,
+				OrderID:        sell.ID,
+				MatchedOrderID: buy.ID,
+			}
+			transaction.Owner = buy.Owner
+
+			if err := s.putTransactionInner(ctx, &transaction); err != nil {
+				return nil, err
+			}
+
+			if err := s.setKeyEndorsement(ctx, s.GetTransactionID(ctx, id), buy.Owner, sell.Owner); err != nil {
+				return nil, err
+			}
+
+			if err := fillOrderInner(buy, amount); err != nil {
+				return nil, err
+			}
+			if err := fillOrderInner(sell, amount); err != nil {
+				return nil, err
+			}
+			touched[buy.ID] = true
+			touched[sell.ID] = true
+
+			created = append(created, s.FromTransactionInner(ctx, &transaction))
+			createdIDs = append(createdIDs, id)
+		}
+	}
+
+	for _, order := range append(buys, sells...) {
+		if !touched[order.ID] {
+			continue
+		}
+
+		order.UpdatedBy = clientID
+		if err := s.putOrderInner(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(created) == 0 {
+		return created, nil
+	}
+
+	eventBody, err := NewOrdersMatchedEvent(productID, unitID, createdIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().SetEvent(OrdersMatchedEventKey, eventBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
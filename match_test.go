@@ -0,0 +1,190 @@
+package main
+
+import (
+	"testing"
+)
+
+//Order placed before running the matching, org1 is owned by the owner and org2 by the counterparty
+type matchOrder struct {
+	id           string
+	orderType    string
+	amount       uint32
+	price        uint64
+	currency     string
+	organization string
+}
+
+//Transaction the matching is expected to create, in creation order
+type matchTransaction struct {
+	sell   string
+	buy    string
+	amount uint32
+}
+
+//Orders are matched with price-time priority, filling them partially and sweeping several price levels
+//Open orders are looked up with CouchDB queries and from the composite indexes alike
+func TestMatchOrders(t *testing.T) {
+	owner := newTestIdentity(t, "Org1MSP", "user1", testAttributes(false))
+	counterparty := newTestIdentity(t, "Org2MSP", "user2", testAttributes(false))
+	other := newTestIdentity(t, "Org3MSP", "user3", testAttributes(false))
+	admin := newTestIdentity(t, "Org4MSP", "admin", testAttributes(true))
+
+	cases := []struct {
+		name         string
+		orders       []matchOrder
+		transactions []matchTransaction
+		remaining    map[string]uint32
+	}{
+		{
+			name: "buy partially filled",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 4, price: 100, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 10, price: 100, organization: "org2"},
+			},
+			transactions: []matchTransaction{{sell: "s1", buy: "b1", amount: 4}},
+			remaining:    map[string]uint32{"s1": 0, "b1": 6},
+		},
+		{
+			name: "sell partially filled",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 10, price: 100, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 3, price: 120, organization: "org2"},
+			},
+			transactions: []matchTransaction{{sell: "s1", buy: "b1", amount: 3}},
+			remaining:    map[string]uint32{"s1": 7, "b1": 0},
+		},
+		{
+			name: "buy sweeps several price levels",
+			orders: []matchOrder{
+				{id: "s3", orderType: "SELL", amount: 5, price: 130, organization: "org1"},
+				{id: "s2", orderType: "SELL", amount: 5, price: 110, organization: "org1"},
+				{id: "s1", orderType: "SELL", amount: 5, price: 100, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 12, price: 120, organization: "org2"},
+			},
+			transactions: []matchTransaction{
+				{sell: "s1", buy: "b1", amount: 5},
+				{sell: "s2", buy: "b1", amount: 5},
+			},
+			remaining: map[string]uint32{"s1": 0, "s2": 0, "s3": 5, "b1": 2},
+		},
+		{
+			name: "sell fills several buys from the highest price",
+			orders: []matchOrder{
+				{id: "b2", orderType: "BUY", amount: 5, price: 110, organization: "org2"},
+				{id: "b1", orderType: "BUY", amount: 5, price: 120, organization: "org2"},
+				{id: "b3", orderType: "BUY", amount: 5, price: 90, organization: "org2"},
+				{id: "s1", orderType: "SELL", amount: 8, price: 100, organization: "org1"},
+			},
+			transactions: []matchTransaction{
+				{sell: "s1", buy: "b1", amount: 5},
+				{sell: "s1", buy: "b2", amount: 3},
+			},
+			remaining: map[string]uint32{"b1": 0, "b2": 2, "b3": 5, "s1": 0},
+		},
+		{
+			name: "oldest order first at the same price",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 5, price: 100, organization: "org1"},
+				{id: "s2", orderType: "SELL", amount: 5, price: 100, organization: "org1"},
+				{id: "s3", orderType: "SELL", amount: 5, price: 90, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 8, price: 100, organization: "org2"},
+			},
+			transactions: []matchTransaction{
+				{sell: "s3", buy: "b1", amount: 5},
+				{sell: "s1", buy: "b1", amount: 3},
+			},
+			remaining: map[string]uint32{"s1": 2, "s2": 5, "s3": 0, "b1": 0},
+		},
+		{
+			name: "several buys and sells",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 4, price: 100, organization: "org1"},
+				{id: "s2", orderType: "SELL", amount: 6, price: 105, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 3, price: 110, organization: "org2"},
+				{id: "b2", orderType: "BUY", amount: 5, price: 105, organization: "org2"},
+			},
+			transactions: []matchTransaction{
+				{sell: "s1", buy: "b1", amount: 3},
+				{sell: "s1", buy: "b2", amount: 1},
+				{sell: "s2", buy: "b2", amount: 4},
+			},
+			remaining: map[string]uint32{"s1": 0, "s2": 2, "b1": 0, "b2": 0},
+		},
+		{
+			name: "prices don't cross",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 5, price: 100, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 5, price: 99, organization: "org2"},
+			},
+			remaining: map[string]uint32{"s1": 5, "b1": 5},
+		},
+		{
+			name: "same organization",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 5, price: 100, organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 5, price: 100, organization: "org1"},
+			},
+			remaining: map[string]uint32{"s1": 5, "b1": 5},
+		},
+		{
+			name: "different currencies",
+			orders: []matchOrder{
+				{id: "s1", orderType: "SELL", amount: 5, price: 100, currency: "USD", organization: "org1"},
+				{id: "b1", orderType: "BUY", amount: 5, price: 100, organization: "org2"},
+			},
+			remaining: map[string]uint32{"s1": 5, "b1": 5},
+		},
+	}
+
+	for _, c := range cases {
+		for _, indexed := range []bool{false, true} {
+			c, indexed := c, indexed
+			name := c.name
+			if indexed {
+				name += "/composite indexes"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				f := newOwnershipFixture(t, owner, counterparty, other, admin)
+				s := f.contract
+				f.must(s.SetCompositeIndexes(f.as(admin), indexed))
+				f.must(s.CreateProduct(f.as(owner), "p2", "Matched product", "", "kg"))
+
+				identities := map[string][]byte{"org1": owner, "org2": counterparty}
+				for _, o := range c.orders {
+					currency := o.currency
+					if currency == "" {
+						currency = "EUR"
+					}
+
+					f.must(s.CreateOrder(f.as(identities[o.organization]), o.id, o.amount, o.price, 2, currency, o.orderType, o.organization, "p2", "kg"))
+				}
+
+				transactions, err := s.MatchOrders(f.as(owner), "p2", "kg")
+				f.must(err)
+
+				if len(transactions) != len(c.transactions) {
+					t.Fatalf("expected %d transactions, got %d", len(c.transactions), len(transactions))
+				}
+
+				for i, want := range c.transactions {
+					got := transactions[i]
+					if got.OrderID != want.sell || got.MatchedOrderID != want.buy || got.Amount != want.amount {
+						t.Fatalf("expected transaction %d to match %s with %s for %d, got %s with %s for %d",
+							i, want.sell, want.buy, want.amount, got.OrderID, got.MatchedOrderID, got.Amount)
+					}
+				}
+
+				for id, remaining := range c.remaining {
+					order, err := s.GetOrderInner(f.as(owner), id)
+					f.must(err)
+
+					status := getOrderFillStatus(order)
+					if order.Remaining != remaining || order.Status != status {
+						t.Fatalf("expected order %s to have %d remaining and status %s, got %d and %s", id, remaining, status, order.Remaining, order.Status)
+					}
+				}
+			})
+		}
+	}
+}
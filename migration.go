@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Maintenance transactions upgrading the records written by earlier versions of the chaincode
+//Only admins can run them, and running one again leaves already upgraded records unchanged
+
+//Calls fn with the key and value of every record stored under the given doctype prefix, in key order
+//Reads the keys directly so it works on both LevelDB and CouchDB
+func forEachDoc(ctx contractapi.TransactionContextInterface, docType DocType, fn func(key string, value []byte) error) error {
+	prefix := string(docType) + "_"
+
+	results, err := ctx.GetStub().GetStateByRange(prefix, prefix+"\uffff")
+	if err != nil {
+		return newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return newError(ErrInternal, "failed to get assets:%v", err)
+		}
+
+		if err := fn(queryResult.Key, queryResult.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Sets the filled and remaining quantities of orders created before they were tracked
+//The filled quantity is the sum of the transactions of the order that were not canceled
+//Returns the number of orders that were changed
+func (s *SmartContract) MigrateOrderFills(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return 0, err
+	}
+
+	filled := make(map[string]uint64)
+	err := forEachDoc(ctx, TransactionDoc, func(_ string, value []byte) error {
+		var transaction TransactionInner
+		if err := json.Unmarshal(value, &transaction); err != nil {
+			return err
+		}
+
+		if !isTransactionFilling(transaction.Status) {
+			return nil
+		}
+
+		for _, orderID := range getTransactionOrderIDs(&transaction) {
+			filled[orderID] += uint64(transaction.Amount)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var orders []*OrderInner
+	err = forEachDoc(ctx, OrderDoc, func(key string, value []byte) error {
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+
+		//Orders tracking their fills always have one of the two quantities set
+		if order.Amount == 0 || order.Filled != 0 || order.Remaining != 0 {
+			return nil
+		}
+
+		order.ID = strings.TrimPrefix(key, string(OrderDoc)+"_")
+		orders = append(orders, &order)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, order := range orders {
+		amount := filled[order.ID]
+		if amount > uint64(order.Amount) {
+			amount = uint64(order.Amount)
+		}
+
+		order.Filled = uint32(amount)
+		order.Remaining = order.Amount - order.Filled
+		if order.Status != OrderStatusClosed {
+			order.Status = getOrderFillStatus(order)
+		}
+		order.UpdatedBy = clientID
+
+		if err := s.putOrderInner(ctx, order); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(orders), nil
+}
+
+//Moves the organizations stored under the unit key prefix by earlier versions of the chaincode to their own key
+//Those records have the unit doctype, they are told apart from units by their address field
+//Organizations already stored under the new key are left in place
+//Returns the number of organizations that were moved
+func (s *SmartContract) MigrateOrganizationKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return 0, err
+	}
+
+	var organizations []*OrganizationInner
+	err := forEachDoc(ctx, UnitDoc, func(key string, value []byte) error {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return err
+		}
+
+		if _, ok := fields["address"]; !ok {
+			return nil
+		}
+
+		var organization OrganizationInner
+		if err := json.Unmarshal(value, &organization); err != nil {
+			return err
+		}
+
+		organization.Type = OrganizationDoc
+		organization.ID = strings.TrimPrefix(key, string(UnitDoc)+"_")
+
+		organizations = append(organizations, &organization)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, organization := range organizations {
+		exists, err := s.OrganizationExist(ctx, organization.ID)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			continue
+		}
+
+		if err := s.putOrganizationInner(ctx, organization); err != nil {
+			return 0, err
+		}
+
+		err = ctx.GetStub().DelState(s.GetUnitID(ctx, organization.ID))
+		if err != nil {
+			return 0, newError(ErrInternal, "failed to delete asset %s: %v", organization.ID, err)
+		}
+
+		moved++
+	}
+
+	return moved, nil
+}
+
+//Writes the composite key index entries of the documents stored before the indexes were kept
+//Covers the order and transaction indexes and the reference index of every document storing the ID of another record
+//Entries already in place are written again with the same value
+//Returns the number of documents that were indexed
+func (s *SmartContract) MigrateCompositeIndexes(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return 0, err
+	}
+
+	indexed := 0
+	err := forEachDoc(ctx, OrderDoc, func(key string, value []byte) error {
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+
+		order.ID = strings.TrimPrefix(key, string(OrderDoc)+"_")
+		indexed++
+		if err := s.putReferenceIndexes(ctx, OrderDoc, order.ID, value); err != nil {
+			return err
+		}
+
+		return s.putOrderIndexes(ctx, &order)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = forEachDoc(ctx, TransactionDoc, func(key string, value []byte) error {
+		var transaction TransactionInner
+		if err := json.Unmarshal(value, &transaction); err != nil {
+			return err
+		}
+
+		transaction.ID = strings.TrimPrefix(key, string(TransactionDoc)+"_")
+		indexed++
+		if err := s.putReferenceIndexes(ctx, TransactionDoc, transaction.ID, value); err != nil {
+			return err
+		}
+
+		return s.putTransactionIndexes(ctx, &transaction)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, docType := range []DocType{ProductDoc, OfferDoc, RequestDoc, AgreementDoc} {
+		docType := docType
+		err = forEachDoc(ctx, docType, func(key string, value []byte) error {
+			indexed++
+			return s.putReferenceIndexes(ctx, docType, strings.TrimPrefix(key, string(docType)+"_"), value)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return indexed, nil
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -14,20 +14,39 @@ const (
 
 //Represents data stored in database
 //Contains the doctype
+//ValidUntilUTC stores the deadline in SortableTimeLayout for queries, it is omitted when the offer has no deadline
 type OfferInner struct {
 	Doc
 
-	ID             string `json:"id"`
-	Value          Price  `json:"value"`
-	OrganizationID string `json:"organization_id"`
-	RequestID      string `json:"request_id"`
+	ID             string      `json:"id"`
+	Value          Price       `json:"value"`
+	Status         OfferStatus `json:"status"`
+	OrganizationID string      `json:"organization_id"`
+	RequestID      string      `json:"request_id"`
+	ValidUntil     time.Time   `json:"valid_until"`
+	ValidUntilUTC  string      `json:"valid_until_utc,omitempty"`
+	Sealed         bool        `json:"sealed"`
+	Commitment     string      `json:"commitment,omitempty"`
+	PrivateValue   bool        `json:"private_value"`
+	ValueHash      string      `json:"value_hash,omitempty"`
 }
 
 type Offer struct {
-	ID             string `json:"id"`
-	Value          Price  `json:"value"`
-	OrganizationID string `json:"organization_id"`
-	RequestID      string `json:"request_id"`
+	ID             string      `json:"id"`
+	Value          Price       `json:"value"`
+	Status         OfferStatus `json:"status"`
+	OrganizationID string      `json:"organization_id"`
+	RequestID      string      `json:"request_id"`
+	ValidUntil     time.Time   `json:"valid_until"`
+	Sealed         bool        `json:"sealed"`
+	Commitment     string      `json:"commitment,omitempty"`
+	PrivateValue   bool        `json:"private_value"`
+	ValueHash      string      `json:"value_hash,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	Archived       bool        `json:"archived,omitempty"`
+	ArchivedAt     time.Time   `json:"archived_at"`
+	Owner          string      `json:"owner"`
 }
 
 //Parse offer from the data on the database
@@ -39,8 +58,19 @@ func (s *SmartContract) FromOfferInner(_ contractapi.TransactionContextInterface
 			Currency: p.Value.Currency,
 			Exponent: p.Value.Exponent,
 		},
+		Status:         p.Status,
 		OrganizationID: p.OrganizationID,
 		RequestID:      p.RequestID,
+		ValidUntil:     p.ValidUntil,
+		Sealed:         p.Sealed,
+		Commitment:     p.Commitment,
+		PrivateValue:   p.PrivateValue,
+		ValueHash:      p.ValueHash,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+		Archived:       p.Archived,
+		ArchivedAt:     p.ArchivedAt,
+		Owner:          p.Owner,
 	}
 }
 
@@ -52,33 +82,152 @@ func (s *SmartContract) GetOfferID(_ contractapi.TransactionContextInterface, id
 func (s *SmartContract) OfferExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetOfferID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state:%v", err)
+		return false, newError(ErrInternal, "failed to read from world state:%v", err)
 	}
 
 	return assetJSON != nil, nil
 }
 
+//Returns the deadline of an offer made at the given time that is valid for the given number of seconds
+//A validity of 0 means the offer has no deadline
+func getOfferValidUntil(now time.Time, validity uint32) time.Time {
+	if validity == 0 {
+		return time.Time{}
+	}
+
+	return now.Add(time.Duration(validity) * time.Second)
+}
+
+//Checks whether the deadline of the offer has passed at the given time
+func isOfferExpired(offer *OfferInner, now time.Time) bool {
+	return !offer.ValidUntil.IsZero() && now.After(offer.ValidUntil)
+}
+
+//Sets the status of a stored pending offer from its request and its deadline
+//Rejecting the other offers of a request or passing a deadline doesn't write the offers, their key endorsement policy would need the MSP of every bidder
+//Offers cannot be searched by status for this reason, a query only sees the stored status
+func (s *SmartContract) resolveOfferStatus(ctx contractapi.TransactionContextInterface, offer *OfferInner) error {
+	if offer.Status != OfferStatusPending {
+		return nil
+	}
+
+	requestBytes, err := ctx.GetStub().GetState(s.GetRequestID(ctx, offer.RequestID))
+	if err != nil {
+		return newError(ErrInternal, "failed to get asset %s:%v", offer.RequestID, err)
+	}
+
+	if requestBytes != nil {
+		var request RequestInner
+		if err := json.Unmarshal(requestBytes, &request); err != nil {
+			return err
+		}
+
+		switch request.AcceptedOfferID {
+		case "":
+		case offer.ID:
+			offer.Status = OfferStatusAccepted
+			return nil
+		default:
+			offer.Status = OfferStatusRejected
+			return nil
+		}
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isOfferExpired(offer, now) {
+		offer.Status = OfferStatusExpired
+	}
+
+	return nil
+}
+
 //Creates a new offer for the request with the given ID
 //User inputs the ID of the offer, the total value of money, the currency, the exponent (number of decimals), the ID of the organization and the ID of the request
-func (s *SmartContract) MakeOffer(ctx contractapi.TransactionContextInterface, id string, value uint32, currency string, exponent uint32, organizationID string, requestID string) error {
+//The offer has no deadline, use MakeOfferWithValidity to set one
+func (s *SmartContract) MakeOffer(ctx contractapi.TransactionContextInterface, id string, value uint64, currency string, exponent uint32, organizationID string, requestID string) error {
+	return s.MakeOfferWithValidity(ctx, id, value, currency, exponent, organizationID, requestID, 0)
+}
+
+//Creates a new offer for the request with the given ID that expires after the given number of seconds
+//User inputs the same arguments as MakeOffer and for how many seconds the offer is valid (0 for no deadline)
+func (s *SmartContract) MakeOfferWithValidity(ctx contractapi.TransactionContextInterface, id string, value uint64, currency string, exponent uint32, organizationID string, requestID string, validity uint32) error {
+	p := Price{
+		Amount:   value,
+		Currency: currency,
+		Exponent: exponent,
+	}
+
+	return s.makeOffer(ctx, id, p, false, organizationID, requestID, validity)
+}
+
+//Creates a new offer for the request with the given ID whose value is kept in the private data collections of the offering organization and the organization of the request
+//Only the hash of the value is stored on the public ledger
+//User inputs the same arguments as MakeOfferWithValidity without the value, which is read as JSON from the "price" key of the transient map
+//The "salt" key of the transient map must hold a random salt of at least 16 bytes, stored and hashed with the value
+func (s *SmartContract) MakePrivateOffer(ctx contractapi.TransactionContextInterface, id string, organizationID string, requestID string, validity uint32) error {
+	p, err := getTransientPrice(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.makeOffer(ctx, id, *p, true, organizationID, requestID, validity)
+}
+
+func (s *SmartContract) makeOffer(ctx contractapi.TransactionContextInterface, id string, value Price, private bool, organizationID string, requestID string, validity uint32) error {
+	if err := validate(
+		field("id", id, isID),
+		field("value", value.Amount, nonZero),
+		field("currency", value.Currency, isCurrency),
+		field("exponent", value.Exponent, maxValue(MaxPriceExponent)),
+		field("organization_id", organizationID, isID),
+		field("request_id", requestID, isID),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, OffersCreate); err != nil {
 		return err
 	}
 
+	mspID, err := s.getOrganizationMSPID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, mspID); err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationActive(ctx, organizationID); err != nil {
+		return err
+	}
+
 	exists, err := s.OfferExist(ctx, id)
 	if err != nil {
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
 	hasOrg, err := s.OrganizationExist(ctx, organizationID)
 	if err != nil {
 		return err
 	}
-	if hasOrg {
-		return fmt.Errorf("organization %s does not exist", id)
+	if !hasOrg {
+		return newError(ErrNotFound, "organization %s does not exist", organizationID)
+	}
+
+	archived, err := isArchived(ctx, s.GetOrganizationID(ctx, organizationID))
+	if err != nil {
+		return err
+	}
+	if archived {
+		return newError(ErrInvalidState, "organization %s is archived", organizationID)
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -86,59 +235,302 @@ func (s *SmartContract) MakeOffer(ctx contractapi.TransactionContextInterface, i
 		return err
 	}
 
-	request, err := s.GetRequest(ctx, requestID)
+	request, err := s.GetRequestInner(ctx, requestID)
 	if err != nil {
 		return err
 	}
 
 	if request.Status == RequestStatusClosed {
-		return fmt.Errorf("request is closed, can't offer")
+		return newError(ErrInvalidState, "request is closed, can't offer")
+	}
+
+	if request.Sealed {
+		return newError(ErrInvalidState, "request is sealed, use MakeSealedOffer")
 	}
 
-	doc := Doc{
-		Type:      OfferDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	doc, err := s.newDoc(ctx, OfferDoc, clientID)
+	if err != nil {
+		return err
 	}
 
+	doc.Owner = mspID
+
 	offer := OfferInner{
-		Doc: doc,
-		ID:  s.GetOfferID(ctx, id),
-		Value: Price{
-			Amount:   value,
-			Currency: currency,
-			Exponent: exponent,
-		},
+		Doc:            doc,
+		ID:             s.GetOfferID(ctx, id),
+		Value:          value,
+		Status:         OfferStatusPending,
 		OrganizationID: organizationID,
 		RequestID:      requestID,
+		ValidUntil:     getOfferValidUntil(now, validity),
+	}
+	offer.ValidUntilUTC = sortableTime(offer.ValidUntil)
+
+	if private {
+		if request.Owner == "" {
+			return newError(ErrInvalidState, "request %s has no organization to share the value with", requestID)
+		}
+
+		offer.Value = Price{}
+		offer.PrivateValue = true
+		offer.ValueHash, err = s.putPrivatePrice(ctx, offer.ID, value, mspID, request.Owner)
+		if err != nil {
+			return err
+		}
 	}
 
 	assetBytes, err := json.Marshal(offer)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to encode asset %s: %v", offer.ID, err)
 	}
 
 	err = ctx.GetStub().PutState(offer.ID, assetBytes)
 	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", offer.ID, err)
+	}
+
+	if err := s.putReferenceIndexes(ctx, OfferDoc, id, assetBytes); err != nil {
 		return err
 	}
 
-	return nil
+	//The requesting organization must agree to every change of the offer, so it can also write the offer when accepting it
+	return s.setKeyEndorsement(ctx, offer.ID, mspID, request.Owner)
+}
+
+//Returns the pending offer with the given ID if the submitting client belongs to the organization that made it or is an admin
+func (s *SmartContract) getOwnPendingOfferInner(ctx contractapi.TransactionContextInterface, id string) (*OfferInner, error) {
+	offer, err := s.GetOfferInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(ctx, offer.Owner); err != nil {
+		return nil, err
+	}
+
+	if offer.Status != OfferStatusPending {
+		return nil, newError(ErrInvalidState, "offer %s is %s", id, offer.Status)
+	}
+
+	return offer, nil
+}
+
+//Withdraws the offer with the given ID
+//Only the organization that made the offer can withdraw it, and only while it is pending
+func (s *SmartContract) WithdrawOffer(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OffersUpdate); err != nil {
+		return err
+	}
+
+	offer, err := s.getOwnPendingOfferInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	offer.Status = OfferStatusWithdrawn
+	offer.UpdatedBy = clientID
+
+	return s.putOfferInner(ctx, offer)
+}
+
+//Replaces the value of the offer with the given ID
+//Only the organization that made the offer can revise it, and only while it is pending and the request is open
+//User inputs the ID of the offer, the total value of money, the currency, the exponent (number of decimals) and for how many seconds the revised offer is valid (0 for no deadline)
+func (s *SmartContract) ReviseOffer(ctx contractapi.TransactionContextInterface, id string, value uint64, currency string, exponent uint32, validity uint32) error {
+	if err := validate(
+		field("id", id, isID),
+		field("value", value, nonZero),
+		field("currency", currency, isCurrency),
+		field("exponent", exponent, maxValue(MaxPriceExponent)),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OffersUpdate); err != nil {
+		return err
+	}
+
+	price := Price{
+		Amount:   value,
+		Currency: currency,
+		Exponent: exponent,
+	}
+
+	offer, err := s.getOwnPendingOfferInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isOfferExpired(offer, now) {
+		return newError(ErrInvalidState, "offer %s has expired", id)
+	}
+
+	if offer.Sealed || offer.Commitment != "" {
+		return newError(ErrInvalidState, "sealed offers can't be revised")
+	}
+
+	if offer.PrivateValue {
+		return newError(ErrInvalidState, "private offers can't be revised")
+	}
+
+	request, err := s.GetRequest(ctx, offer.RequestID)
+	if err != nil {
+		return err
+	}
+
+	if request.Status == RequestStatusClosed {
+		return newError(ErrInvalidState, "request is closed, can't revise offer")
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	offer.Value = price
+	offer.ValidUntil = getOfferValidUntil(now, validity)
+	offer.ValidUntilUTC = sortableTime(offer.ValidUntil)
+	offer.UpdatedBy = clientID
+
+	return s.putOfferInner(ctx, offer)
+}
+
+//Archives the offer with the given ID
+//Only the organization that made the offer can delete it, a pending offer is withdrawn first
+func (s *SmartContract) DeleteOffer(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OffersDelete); err != nil {
+		return err
+	}
+
+	offer, err := s.getStoredOfferInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, offer.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&offer.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	resolved := *offer
+	if err := s.resolveOfferStatus(ctx, &resolved); err != nil {
+		return err
+	}
+
+	if resolved.Status == OfferStatusPending {
+		offer.Status = OfferStatusWithdrawn
+	}
+
+	return s.putOfferInner(ctx, offer)
+}
+
+//Restores the archived offer with the given ID
+//A withdrawn offer stays withdrawn
+func (s *SmartContract) RestoreOffer(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OffersDelete); err != nil {
+		return err
+	}
+
+	offer, err := s.getStoredOfferInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, offer.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&offer.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putOfferInner(ctx, offer)
+}
+
+//Stores the given OfferInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetOfferInner
+func (s *SmartContract) putOfferInner(ctx contractapi.TransactionContextInterface, offer *OfferInner) error {
+	if err := s.touchDoc(ctx, &offer.Doc); err != nil {
+		return err
+	}
+
+	stored := *offer
+	stored.ID = s.GetOfferID(ctx, offer.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
+	}
+
+	return s.putReferenceIndexes(ctx, OfferDoc, offer.ID, assetBytes)
 }
 
 //Returns OfferInner with the given ID
 func (s *SmartContract) GetOfferInner(ctx contractapi.TransactionContextInterface, id string) (*OfferInner, error) {
+	o, err := s.getStoredOfferInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveOfferStatus(ctx, o); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+//Returns OfferInner with the given ID and the status it is stored with
+//Used by the functions writing the offer back, which must not store a status derived by resolveOfferStatus
+func (s *SmartContract) getStoredOfferInner(ctx contractapi.TransactionContextInterface, id string) (*OfferInner, error) {
 	if err := s.HasPermission(ctx, OffersRead); err != nil {
 		return nil, err
 	}
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetOfferID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var o OfferInner
@@ -148,6 +540,10 @@ func (s *SmartContract) GetOfferInner(ctx contractapi.TransactionContextInterfac
 	}
 
 	o.ID = strings.TrimPrefix(o.ID, string(OfferDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &o.Doc, o.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return &o, nil
 }
 
@@ -159,11 +555,11 @@ func (s *SmartContract) GetOffer(ctx contractapi.TransactionContextInterface, id
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetOfferID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var o OfferInner
@@ -173,6 +569,14 @@ func (s *SmartContract) GetOffer(ctx contractapi.TransactionContextInterface, id
 	}
 
 	o.ID = strings.TrimPrefix(o.ID, string(OfferDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &o.Doc, o.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveOfferStatus(ctx, &o); err != nil {
+		return nil, err
+	}
+
 	return s.FromOfferInner(ctx, &o), nil
 }
 
@@ -182,9 +586,14 @@ func (s *SmartContract) GetAllOffersForRequestInner(ctx contractapi.TransactionC
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","request_id":"%s"}}`, OfferDoc, requestID))
+	query, err := offersForRequestQuery(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -201,7 +610,15 @@ func (s *SmartContract) GetAllOffersForRequestInner(ctx contractapi.TransactionC
 			return nil, err
 		}
 
-		o.ID = strings.TrimPrefix(o.ID, string(OfferDoc)+" _ ")
+		o.ID = strings.TrimPrefix(o.ID, string(OfferDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &o.Doc, o.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		if err := s.resolveOfferStatus(ctx, &o); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, &o)
 	}
 
@@ -214,9 +631,14 @@ func (s *SmartContract) GetAllOffersForRequest(ctx contractapi.TransactionContex
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","request_id":"%s"}}`, OfferDoc, requestID))
+	query, err := offersForRequestQuery(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -233,8 +655,55 @@ func (s *SmartContract) GetAllOffersForRequest(ctx contractapi.TransactionContex
 		}
 
 		o.ID = strings.TrimPrefix(o.ID, string(OfferDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &o.Doc, o.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		if err := s.resolveOfferStatus(ctx, &o); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromOfferInner(ctx, &o))
 	}
 
 	return assets, nil
 }
+
+//Returns one page of all Offer for the request with the given ID
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllOffersForRequestWithPagination(ctx contractapi.TransactionContextInterface, requestID string, pageSize int32, bookmark string) (*OffersPage, error) {
+	if err := s.HasPermission(ctx, OffersRead); err != nil {
+		return nil, err
+	}
+
+	query, err := offersForRequestQuery(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OffersPage{Records: []*Offer{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var offer OfferInner
+		if err := json.Unmarshal(value, &offer); err != nil {
+			return err
+		}
+
+		offer.ID = strings.TrimPrefix(offer.ID, string(OfferDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &offer.Doc, offer.OrganizationID); err != nil {
+			return err
+		}
+
+		if err := s.resolveOfferStatus(ctx, &offer); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromOfferInner(ctx, &offer))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
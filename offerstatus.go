@@ -0,0 +1,32 @@
+package main
+
+const (
+	OfferStatusPending   OfferStatus = "PENDING"
+	OfferStatusWithdrawn OfferStatus = "WITHDRAWN"
+	OfferStatusAccepted  OfferStatus = "ACCEPTED"
+	OfferStatusRejected  OfferStatus = "REJECTED"
+	OfferStatusExpired   OfferStatus = "EXPIRED"
+)
+
+type OfferStatus string
+
+func (o OfferStatus) String() string {
+	return string(o)
+}
+
+func ParseOfferStatus(status string) (OfferStatus, error) {
+	switch status {
+	case "PENDING":
+		return OfferStatusPending, nil
+	case "WITHDRAWN":
+		return OfferStatusWithdrawn, nil
+	case "ACCEPTED":
+		return OfferStatusAccepted, nil
+	case "REJECTED":
+		return OfferStatusRejected, nil
+	case "EXPIRED":
+		return OfferStatusExpired, nil
+	}
+
+	return "", newError(ErrInvalidArgument, "invalid offer status")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	OnboardingConfigKey          = "config_onboarding"
+	OrganizationApprovedEventKey = "organization_approved"
+)
+
+//Settings of the organization onboarding workflow
+//Quorum is the number of active organizations that must approve a new organization, 0 requires a majority of them
+type OnboardingConfig struct {
+	Quorum uint32 `json:"quorum"`
+}
+
+type OrganizationApprovedEvent struct {
+	OrganizationID    string             `json:"organization_id"`
+	ApprovedBy        string             `json:"approved_by"`
+	Approvals         int                `json:"approvals"`
+	RequiredApprovals int                `json:"required_approvals"`
+	Status            OrganizationStatus `json:"status"`
+}
+
+func NewOrganizationApprovedEvent(id string, approvedBy string, approvals int, requiredApprovals int, status OrganizationStatus) ([]byte, error) {
+	return json.Marshal(OrganizationApprovedEvent{OrganizationID: id, ApprovedBy: approvedBy, Approvals: approvals, RequiredApprovals: requiredApprovals, Status: status})
+}
+
+//Returns how many approvals a new organization needs with the given quorum and number of active organizations
+//The quorum is capped to the number of active organizations, so a proposal can always be approved
+func getRequiredApprovals(quorum uint32, active int) int {
+	if quorum == 0 {
+		return active/2 + 1
+	}
+
+	if int(quorum) > active {
+		return active
+	}
+
+	return int(quorum)
+}
+
+//Approves the pending organization with the given ID on behalf of the organization of the current user
+//Only active organizations can approve, each of them once, and an organization can't approve itself
+//The organization becomes ACTIVE once it has the number of approvals required by the quorum
+func (s *SmartContract) ApproveOrganization(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OrganizationsUpdate); err != nil {
+		return err
+	}
+
+	organization, err := s.GetOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if organization.Archived || organizationStatus(organization) != OrganizationStatusPending {
+		return newError(ErrInvalidState, "organization %s is not pending approval", id)
+	}
+
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	if mspID == organizationMSPID(organization) {
+		return newError(ErrForbidden, "organization %s can't approve itself", id)
+	}
+
+	if err := s.checkMember(ctx, mspID); err != nil {
+		return err
+	}
+
+	activeMSPIDs, err := s.getActiveOrganizationMSPIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	isActive := false
+	for _, active := range activeMSPIDs {
+		if active == mspID {
+			isActive = true
+			break
+		}
+	}
+
+	if !isActive {
+		return newError(ErrForbidden, "only active organizations can approve new organizations")
+	}
+
+	for _, approval := range organization.Approvals {
+		if approval == mspID {
+			return newError(ErrConflict, "organization %s was already approved by %s", id, mspID)
+		}
+	}
+
+	config, err := s.getOnboardingConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	required := getRequiredApprovals(config.Quorum, len(activeMSPIDs))
+
+	organization.Approvals = append(organization.Approvals, mspID)
+	organization.UpdatedBy = clientID
+	if len(organization.Approvals) >= required {
+		organization.Status = OrganizationStatusActive
+	}
+
+	if err := s.putOrganizationInner(ctx, organization); err != nil {
+		return err
+	}
+
+	eventBody, err := NewOrganizationApprovedEvent(id, mspID, len(organization.Approvals), required, organization.Status)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(OrganizationApprovedEventKey, eventBody)
+}
+
+//Sets how many active organizations must approve a new organization
+//0 requires a majority of the active organizations
+func (s *SmartContract) SetOrganizationQuorum(ctx contractapi.TransactionContextInterface, quorum uint32) error {
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return err
+	}
+
+	configBytes, err := json.Marshal(OnboardingConfig{Quorum: quorum})
+	if err != nil {
+		return newError(ErrInternal, "failed to encode onboarding config: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(OnboardingConfigKey, configBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to store onboarding config: %v", err)
+	}
+
+	return nil
+}
+
+//Returns the settings of the organization onboarding workflow
+func (s *SmartContract) GetOnboardingConfig(ctx contractapi.TransactionContextInterface) (*OnboardingConfig, error) {
+	if err := s.HasPermission(ctx, OrganizationsRead); err != nil {
+		return nil, err
+	}
+
+	return s.getOnboardingConfig(ctx)
+}
+
+func (s *SmartContract) getOnboardingConfig(ctx contractapi.TransactionContextInterface) (*OnboardingConfig, error) {
+	configBytes, err := ctx.GetStub().GetState(OnboardingConfigKey)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to read onboarding config: %v", err)
+	}
+
+	var config OnboardingConfig
+	if configBytes == nil {
+		return &config, nil
+	}
+
+	err = json.Unmarshal(configBytes, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+//Returns the MSP IDs of the active, not archived organizations
+//Read from a range of organization keys, so every endorsing peer reads the same keys
+//Organizations registered before MSP IDs were recorded are not in the MSP index, they are counted under the MSP ID they used as ID
+func (s *SmartContract) getActiveOrganizationMSPIDs(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	var mspIDs []string
+	seen := make(map[string]bool)
+	err := forEachDoc(ctx, OrganizationDoc, func(_ string, value []byte) error {
+		var organization OrganizationInner
+		if err := json.Unmarshal(value, &organization); err != nil {
+			return err
+		}
+
+		if organization.Archived || organizationStatus(&organization) != OrganizationStatusActive {
+			return nil
+		}
+
+		mspID := organizationMSPID(&organization)
+		if seen[mspID] {
+			return nil
+		}
+
+		seen[mspID] = true
+		mspIDs = append(mspIDs, mspID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mspIDs, nil
+}
+
+//Returns whether any organization is stored, archived and pending ones included
+//Organizations still stored under unit keys are not seen until MigrateOrganizationKeys has run
+func (s *SmartContract) hasOrganizations(ctx contractapi.TransactionContextInterface) (bool, error) {
+	prefix := string(OrganizationDoc) + "_"
+
+	results, err := ctx.GetStub().GetStateByRange(prefix, prefix+"\uffff")
+	if err != nil {
+		return false, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	return results.HasNext(), nil
+}
+
+//Checks that the organization with the given ID was approved
+//Pending organizations can't place orders or make offers
+func (s *SmartContract) checkOrganizationActive(ctx contractapi.TransactionContextInterface, id string) error {
+	organization, err := s.getOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if organizationStatus(organization) == OrganizationStatusPending {
+		return newError(ErrInvalidState, "organization %s is pending approval", id)
+	}
+
+	return nil
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"math/big"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -14,28 +16,43 @@ const (
 
 //Represents data stored in database
 //Contains the doctype
+//PriceSharedWith lists the MSP IDs of the organizations a private price was shared with
 type OrderInner struct {
 	Doc
 
-	ID             string      `json:"id"`
-	Amount         uint32      `json:"amount"`
-	Price          Price       `json:"price"`
-	Type           OrderType   `json:"type"`
-	Status         OrderStatus `json:"status"`
-	OrganizationID string      `json:"organization_id"`
-	ProductID      string      `json:"product_id"`
-	UnitID         string      `json:"unit_id"`
+	ID              string      `json:"id"`
+	Amount          uint32      `json:"amount"`
+	Filled          uint32      `json:"filled"`
+	Remaining       uint32      `json:"remaining"`
+	Price           Price       `json:"price"`
+	Type            OrderType   `json:"type"`
+	Status          OrderStatus `json:"status"`
+	OrganizationID  string      `json:"organization_id"`
+	ProductID       string      `json:"product_id"`
+	UnitID          string      `json:"unit_id"`
+	PrivatePrice    bool        `json:"private_price"`
+	PriceHash       string      `json:"price_hash,omitempty"`
+	PriceSharedWith []string    `json:"price_shared_with,omitempty"`
 }
 
 type Order struct {
 	ID           string        `json:"id"`
 	Amount       uint32        `json:"amount"`
+	Filled       uint32        `json:"filled"`
+	Remaining    uint32        `json:"remaining"`
 	Price        Price         `json:"price"`
 	Type         OrderType     `json:"type"`
 	Status       OrderStatus   `json:"status"`
 	Organization *Organization `json:"organization_id"`
 	Product      *Product      `json:"product_id"`
 	Unit         *Unit         `json:"unit_id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	PrivatePrice bool          `json:"private_price"`
+	PriceHash    string        `json:"price_hash,omitempty"`
+	Archived     bool          `json:"archived,omitempty"`
+	ArchivedAt   time.Time     `json:"archived_at"`
+	Owner        string        `json:"owner"`
 }
 
 //Parse order from the data on the database
@@ -45,8 +62,10 @@ func (s *SmartContract) FromOrderInner(ctx contractapi.TransactionContextInterfa
 	unit, _ := s.GetUnit(ctx, p.UnitID)
 
 	return &Order{
-		ID:     p.ID,
-		Amount: p.Amount,
+		ID:        p.ID,
+		Amount:    p.Amount,
+		Filled:    p.Filled,
+		Remaining: p.Remaining,
 		Price: Price{
 			Amount:   p.Price.Amount,
 			Exponent: p.Price.Exponent,
@@ -57,6 +76,13 @@ func (s *SmartContract) FromOrderInner(ctx contractapi.TransactionContextInterfa
 		Organization: org,
 		Product:      product,
 		Unit:         unit,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+		PrivatePrice: p.PrivatePrice,
+		PriceHash:    p.PriceHash,
+		Archived:     p.Archived,
+		ArchivedAt:   p.ArchivedAt,
+		Owner:        p.Owner,
 	}
 }
 
@@ -68,7 +94,7 @@ func (s *SmartContract) GetOrderID(ctx contractapi.TransactionContextInterface,
 func (s *SmartContract) OrderExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetOrderID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
 	}
 
 	return assetJSON != nil, nil
@@ -83,7 +109,7 @@ func (s *SmartContract) OrdersExist(ctx contractapi.TransactionContextInterface,
 		}
 
 		if !e {
-			return fmt.Errorf("order %s does not exist", id)
+			return newError(ErrNotFound, "order %s does not exist", id)
 		}
 	}
 
@@ -91,15 +117,61 @@ func (s *SmartContract) OrdersExist(ctx contractapi.TransactionContextInterface,
 }
 
 //Creates a new order with the given ID
-//User inputs the ID of the offer, the amount of product being sold, the total value of money, the exponent (number of decimals), the currency, the type of Order (BUY or SELL), the ID of the organization, the ID of the product and the ID of the unit
-func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface, id string, amount uint32, price uint32, priceExponent uint32, currency string, typeInput string, organizationID string, productID string, unitID string) error {
+//User inputs the ID of the order, the amount of product being sold, the price per unit of product, the exponent (number of decimals), the currency, the type of Order (BUY or SELL), the ID of the organization, the ID of the product and the ID of the unit
+//The unit can be any unit compatible with the units of the product, the amount and price are then converted to the product unit
+func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface, id string, amount uint32, price uint64, priceExponent uint32, currency string, typeInput string, organizationID string, productID string, unitID string) error {
+	p := Price{
+		Amount:   price,
+		Exponent: priceExponent,
+		Currency: currency,
+	}
+
+	return s.createOrder(ctx, id, amount, p, false, typeInput, organizationID, productID, unitID)
+}
+
+//Creates a new order with the given ID whose price is kept in the private data collection of the organization
+//Only the hash of the price is stored on the public ledger
+//User inputs the same arguments as CreateOrder without the price, which is read as JSON from the "price" key of the transient map
+//A random salt of at least 16 bytes must be passed in the "salt" key of the transient map, it is stored with the price and hashed with it
+func (s *SmartContract) CreatePrivateOrder(ctx contractapi.TransactionContextInterface, id string, amount uint32, typeInput string, organizationID string, productID string, unitID string) error {
+	p, err := getTransientPrice(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.createOrder(ctx, id, amount, *p, true, typeInput, organizationID, productID, unitID)
+}
+
+func (s *SmartContract) createOrder(ctx contractapi.TransactionContextInterface, id string, amount uint32, price Price, private bool, typeInput string, organizationID string, productID string, unitID string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("amount", amount, nonZero),
+		field("price", price.Amount, nonZero),
+		field("currency", price.Currency, isCurrency),
+		field("exponent", price.Exponent, maxValue(MaxPriceExponent)),
+		field("type", typeInput, oneOf(string(OrderTypeBuy), string(OrderTypeSell))),
+		field("organization_id", organizationID, isID),
+		field("product_id", productID, isID),
+		field("unit_id", unitID, isID),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, OrdersCreate); err != nil {
 		return err
 	}
 
-	orgID, err := s.GetSubmittingClientOrganization(ctx)
-	if err != nil || orgID != organizationID {
-		return fmt.Errorf("unauthorized")
+	mspID, err := s.getOrganizationMSPID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, mspID); err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationActive(ctx, organizationID); err != nil {
+		return err
 	}
 
 	exists, err := s.OrderExist(ctx, id)
@@ -107,15 +179,23 @@ func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
-	hasOrg, err := s.OrganizationExist(ctx, productID)
+	hasOrg, err := s.OrganizationExist(ctx, organizationID)
 	if err != nil {
 		return err
 	}
 	if !hasOrg {
-		return fmt.Errorf("organization %s does not exist", id)
+		return newError(ErrNotFound, "organization %s does not exist", organizationID)
+	}
+
+	archived, err := isArchived(ctx, s.GetOrganizationID(ctx, organizationID))
+	if err != nil {
+		return err
+	}
+	if archived {
+		return newError(ErrInvalidState, "organization %s is archived", organizationID)
 	}
 
 	hasProduct, err := s.ProductExist(ctx, productID)
@@ -123,7 +203,15 @@ func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	if !hasProduct {
-		return fmt.Errorf("product %s does not exist", id)
+		return newError(ErrNotFound, "product %s does not exist", productID)
+	}
+
+	archived, err = isArchived(ctx, s.GetProductID(ctx, productID))
+	if err != nil {
+		return err
+	}
+	if archived {
+		return newError(ErrInvalidState, "product %s is archived", productID)
 	}
 
 	hasUnit, err := s.UnitExist(ctx, unitID)
@@ -131,7 +219,25 @@ func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	if !hasUnit {
-		return fmt.Errorf("unit %s does not exist", id)
+		return newError(ErrNotFound, "unit %s does not exist", unitID)
+	}
+
+	archived, err = isArchived(ctx, s.GetUnitID(ctx, unitID))
+	if err != nil {
+		return err
+	}
+	if archived {
+		return newError(ErrInvalidState, "unit %s is archived", unitID)
+	}
+
+	product, err := s.GetProductInner(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	unitID, amount, price, err = s.normalizeOrderUnit(ctx, product, unitID, amount, price)
+	if err != nil {
+		return err
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -144,21 +250,19 @@ func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 
-	doc := Doc{
-		Type:      ProductDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	doc, err := s.newDoc(ctx, OrderDoc, clientID)
+	if err != nil {
+		return err
 	}
 
+	doc.Owner = mspID
+
 	unit := OrderInner{
-		Doc:    doc,
-		ID:     s.GetOrderID(ctx, id),
-		Amount: amount,
-		Price: Price{
-			Amount:   price,
-			Exponent: priceExponent,
-			Currency: currency,
-		},
+		Doc:            doc,
+		ID:             id,
+		Amount:         amount,
+		Remaining:      amount,
+		Price:          price,
 		Type:           _type,
 		Status:         OrderStatusOpen,
 		OrganizationID: organizationID,
@@ -166,17 +270,63 @@ func (s *SmartContract) CreateOrder(ctx contractapi.TransactionContextInterface,
 		UnitID:         unitID,
 	}
 
-	assetBytes, err := json.Marshal(unit)
-	if err != nil {
+	if private {
+		unit.Price = Price{}
+		unit.PrivatePrice = true
+		unit.PriceHash, err = s.putPrivatePrice(ctx, s.GetOrderID(ctx, id), price, mspID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.putOrderInner(ctx, &unit); err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(unit.ID, assetBytes)
+	//Fills are tracked on the order, so the organization of the order endorses every transaction on it and can refuse one
+	return s.setKeyEndorsement(ctx, s.GetOrderID(ctx, id), mspID)
+}
+
+//Resolves the unit of an order to one of the units of the product
+//An amount in a unit the product does not list is converted to the product unit of the same dimension, along with the price per unit
+//Returns the ID of the product unit, the converted amount and the converted price
+func (s *SmartContract) normalizeOrderUnit(ctx contractapi.TransactionContextInterface, product *ProductInner, unitID string, amount uint32, price Price) (string, uint32, Price, error) {
+	for _, id := range product.UnitIDs {
+		if id == unitID {
+			return unitID, amount, price, nil
+		}
+	}
+
+	unit, err := s.GetUnitInner(ctx, unitID)
 	if err != nil {
-		return err
+		return "", 0, Price{}, err
 	}
 
-	return nil
+	for _, id := range product.UnitIDs {
+		productUnit, err := s.GetUnitInner(ctx, id)
+		if err != nil {
+			continue
+		}
+
+		ratio, err := getUnitConversion(unit, productUnit)
+		if err != nil {
+			continue
+		}
+
+		converted, err := convertAmount(amount, ratio)
+		if err != nil {
+			return "", 0, Price{}, err
+		}
+
+		convertedPrice, err := price.MulRat(new(big.Rat).Inv(ratio))
+		if err != nil {
+			return "", 0, Price{}, err
+		}
+
+		return productUnit.ID, converted, convertedPrice, nil
+	}
+
+	return "", 0, Price{}, newError(ErrInvalidArgument, "unit %s is not compatible with product %s", unitID, product.ID)
 }
 
 //Changes status of order to "CLOSED"
@@ -190,7 +340,7 @@ func (s *SmartContract) CloseOrder(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+		return newError(ErrNotFound, "the asset %s does not exist", id)
 	}
 
 	order, err := s.GetOrderInner(ctx, id)
@@ -198,25 +348,172 @@ func (s *SmartContract) CloseOrder(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 
-	orgID, err := s.GetSubmittingClientOrganization(ctx)
-	if err != nil || orgID != order.OrganizationID {
-		return fmt.Errorf("unauthorized")
+	if err := s.checkOwner(ctx, order.Owner); err != nil {
+		return err
 	}
 
 	if order.Status == OrderStatusClosed {
-		return fmt.Errorf("you do not belong in that org")
+		return newError(ErrInvalidState, "order %s is already closed", id)
 	}
 
 	order.Status = OrderStatusClosed
 
-	assetBytes, err := json.Marshal(order)
+	return s.putOrderInner(ctx, order)
+}
+
+//Returns the status matching the filled and remaining quantities of the order
+func getOrderFillStatus(order *OrderInner) OrderStatus {
+	switch {
+	case order.Remaining == 0:
+		return OrderStatusClosed
+	case order.Filled > 0:
+		return OrderStatusPartiallyFilled
+	}
+
+	return OrderStatusOpen
+}
+
+//Moves the given amount from the remaining quantity of the order to its filled quantity
+//The order is closed once there is no remaining quantity
+func fillOrderInner(order *OrderInner, amount uint32) error {
+	if amount > order.Remaining {
+		return newError(ErrInvalidArgument, "invalid amount to transact")
+	}
+
+	order.Filled += amount
+	order.Remaining -= amount
+	order.Status = getOrderFillStatus(order)
+
+	return nil
+}
+
+//Moves the given amount from the filled quantity of the order back to its remaining quantity
+//Orders closed by the owner stay closed, orders closed because they were fully filled are reopened
+func releaseOrderInner(order *OrderInner, amount uint32) error {
+	if amount > order.Filled {
+		return newError(ErrInvalidState, "invalid amount to release")
+	}
+
+	reopen := order.Status != OrderStatusClosed || order.Remaining == 0
+
+	order.Filled -= amount
+	order.Remaining += amount
+	if reopen {
+		order.Status = getOrderFillStatus(order)
+	}
+
+	return nil
+}
+
+//Fills or releases the given amount on the order with the given ID and stores it
+func (s *SmartContract) updateOrderFill(ctx contractapi.TransactionContextInterface, id string, amount uint32, fill bool, clientID string) error {
+	order, err := s.GetOrderInner(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(order.ID, assetBytes)
+	if fill {
+		err = fillOrderInner(order, amount)
+	} else {
+		err = releaseOrderInner(order, amount)
+	}
+	if err != nil {
+		return err
+	}
 
-	return nil
+	order.UpdatedBy = clientID
+
+	return s.putOrderInner(ctx, order)
+}
+
+//Archives the order with the given ID
+//Only the organization of the order can delete it, an open order is closed first
+func (s *SmartContract) DeleteOrder(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OrdersDelete); err != nil {
+		return err
+	}
+
+	order, err := s.GetOrderInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, order.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&order.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	order.Status = OrderStatusClosed
+
+	return s.putOrderInner(ctx, order)
+}
+
+//Restores the archived order with the given ID
+//The order stays closed
+func (s *SmartContract) RestoreOrder(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OrdersDelete); err != nil {
+		return err
+	}
+
+	order, err := s.GetOrderInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, order.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&order.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putOrderInner(ctx, order)
+}
+
+//Stores the given OrderInner under its key in the world state and updates its composite key indexes
+//Expects the ID without the doctype prefix, as returned by GetOrderInner
+func (s *SmartContract) putOrderInner(ctx contractapi.TransactionContextInterface, order *OrderInner) error {
+	if err := s.touchDoc(ctx, &order.Doc); err != nil {
+		return err
+	}
+
+	stored := *order
+	stored.ID = s.GetOrderID(ctx, order.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
+	}
+
+	if err := s.putReferenceIndexes(ctx, OrderDoc, order.ID, assetBytes); err != nil {
+		return err
+	}
+
+	return s.putOrderIndexes(ctx, order)
 }
 
 //Returns Order with given ID
@@ -227,11 +524,11 @@ func (s *SmartContract) GetOrder(ctx contractapi.TransactionContextInterface, id
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetOrderID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var unit OrderInner
@@ -241,6 +538,10 @@ func (s *SmartContract) GetOrder(ctx contractapi.TransactionContextInterface, id
 	}
 
 	unit.ID = strings.TrimPrefix(unit.ID, string(OrderDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return s.FromOrderInner(ctx, &unit), nil
 }
 
@@ -252,11 +553,11 @@ func (s *SmartContract) GetOrderInner(ctx contractapi.TransactionContextInterfac
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetOrderID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var unit OrderInner
@@ -266,18 +567,57 @@ func (s *SmartContract) GetOrderInner(ctx contractapi.TransactionContextInterfac
 	}
 
 	unit.ID = strings.TrimPrefix(unit.ID, string(OrderDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return &unit, nil
 }
 
+//Returns the total value of the order with the given ID, the price per unit multiplied by the amount
+//Private prices are only available to the organizations allowed to read them
+func (s *SmartContract) GetOrderTotal(ctx contractapi.TransactionContextInterface, id string) (*Price, error) {
+	order, err := s.GetOrderInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := s.GetOrderPrice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := price.Mul(uint64(order.Amount))
+	if err != nil {
+		return nil, err
+	}
+
+	return &total, nil
+}
+
 //Returns all Order in the system
 func (s *SmartContract) GetAllOrders(ctx contractapi.TransactionContextInterface) ([]*Order, error) {
 	if err := s.HasPermission(ctx, OrdersRead); err != nil {
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s"}}`, OrderDoc))
+	indexed, err := s.useCompositeIndexes(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrders(ctx, OrderStatusIndex, []string{})
+	}
+
+	query, err := activeDocsQuery(OrderDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -294,11 +634,104 @@ func (s *SmartContract) GetAllOrders(ctx contractapi.TransactionContextInterface
 		}
 
 		unit.ID = strings.TrimPrefix(unit.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromOrderInner(ctx, &unit))
 	}
 	return assets, nil
 }
 
+//Returns one page of all Order
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllOrdersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*OrdersPage, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	page := &OrdersPage{Records: []*Order{}}
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrdersPage(ctx, OrderStatusIndex, []string{}, pageSize, bookmark)
+	}
+
+	query, err := activeDocsQuery(OrderDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+
+		order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromOrderInner(ctx, &order))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
+//Returns all Order created between from (inclusive) and to (exclusive), oldest first
+//Either end of the range can be left open with the zero time
+func (s *SmartContract) GetAllOrdersCreatedBetween(ctx contractapi.TransactionContextInterface, from time.Time, to time.Time) ([]*Order, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	query, err := getCreatedBetweenQuery(OrderDoc, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var assets []*Order
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+		var order OrderInner
+		err = json.Unmarshal(queryResult.Value, &order)
+		if err != nil {
+			return nil, err
+		}
+
+		order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, s.FromOrderInner(ctx, &order))
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return isCreatedBefore(assets[i].CreatedAt, assets[i].ID, assets[j].CreatedAt, assets[j].ID)
+	})
+
+	return assets, nil
+}
+
 //Returns all Order with the given status
 func (s *SmartContract) GetAllOrdersByStatus(ctx contractapi.TransactionContextInterface, statusInput string) ([]*Order, error) {
 	if err := s.HasPermission(ctx, OrdersRead); err != nil {
@@ -310,9 +743,23 @@ func (s *SmartContract) GetAllOrdersByStatus(ctx contractapi.TransactionContextI
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","status":"%s"}}`, OrderDoc, status))
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrders(ctx, OrderStatusIndex, []string{string(status)})
+	}
+
+	query, err := ordersByStatusQuery(status)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -329,21 +776,88 @@ func (s *SmartContract) GetAllOrdersByStatus(ctx contractapi.TransactionContextI
 		}
 
 		unit.ID = strings.TrimPrefix(unit.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromOrderInner(ctx, &unit))
 	}
 
 	return assets, nil
 }
 
+//Returns one page of all Order with the given status
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllOrdersByStatusWithPagination(ctx contractapi.TransactionContextInterface, statusInput string, pageSize int32, bookmark string) (*OrdersPage, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	status, err := ParseOrderStatus(statusInput)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrdersPage{Records: []*Order{}}
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrdersPage(ctx, OrderStatusIndex, []string{string(status)}, pageSize, bookmark)
+	}
+
+	query, err := ordersByStatusQuery(status)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+
+		order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromOrderInner(ctx, &order))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
 //Returns all Order associated to the organization with the given ID
 func (s *SmartContract) GetAllOrdersByOrganization(ctx contractapi.TransactionContextInterface, org string) ([]*Order, error) {
 	if err := s.HasPermission(ctx, OrdersRead); err != nil {
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","organization_id":"%s"}}`, OrderDoc, org))
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrders(ctx, OrderOrgStatusIndex, []string{org})
+	}
+
+	query, err := ordersByOrganizationQuery(org)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -360,13 +874,62 @@ func (s *SmartContract) GetAllOrdersByOrganization(ctx contractapi.TransactionCo
 		}
 
 		unit.ID = strings.TrimPrefix(unit.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromOrderInner(ctx, &unit))
 	}
 
 	return assets, nil
 }
 
+//Returns one page of all Order of the organization with the given ID
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllOrdersByOrganizationWithPagination(ctx contractapi.TransactionContextInterface, org string, pageSize int32, bookmark string) (*OrdersPage, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	page := &OrdersPage{Records: []*Order{}}
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrdersPage(ctx, OrderOrgStatusIndex, []string{org}, pageSize, bookmark)
+	}
+
+	query, err := ordersByOrganizationQuery(org)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+
+		order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromOrderInner(ctx, &order))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
 //Returns all Order associated to the organization with the given ID and the given status
+//Search covers this and any other combination of filters
 func (s *SmartContract) GetAllOrdersByOrganizationAndStatus(ctx contractapi.TransactionContextInterface, org, statusInput string) ([]*Order, error) {
 	if err := s.HasPermission(ctx, OrdersRead); err != nil {
 		return nil, err
@@ -377,9 +940,23 @@ func (s *SmartContract) GetAllOrdersByOrganizationAndStatus(ctx contractapi.Tran
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","organization_id":"%s","status":"%s"}}`, OrderDoc, org, status))
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrders(ctx, OrderOrgStatusIndex, []string{org, string(status)})
+	}
+
+	query, err := ordersByOrganizationAndStatusQuery(org, status)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -396,8 +973,61 @@ func (s *SmartContract) GetAllOrdersByOrganizationAndStatus(ctx contractapi.Tran
 		}
 
 		unit.ID = strings.TrimPrefix(unit.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromOrderInner(ctx, &unit))
 	}
 
 	return assets, nil
 }
+
+//Returns one page of all Order of the organization with the given ID and the given status
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllOrdersByOrganizationAndStatusWithPagination(ctx contractapi.TransactionContextInterface, org string, statusInput string, pageSize int32, bookmark string) (*OrdersPage, error) {
+	if err := s.HasPermission(ctx, OrdersRead); err != nil {
+		return nil, err
+	}
+
+	status, err := ParseOrderStatus(statusInput)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrdersPage{Records: []*Order{}}
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedOrdersPage(ctx, OrderOrgStatusIndex, []string{org, string(status)}, pageSize, bookmark)
+	}
+
+	query, err := ordersByOrganizationAndStatusQuery(org, status)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+
+		order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromOrderInner(ctx, &order))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
@@ -1,12 +1,9 @@
 package main
 
-import (
-	"fmt"
-)
-
 const (
-	OrderStatusOpen   OrderStatus = "OPEN"
-	OrderStatusClosed OrderStatus = "CLOSED"
+	OrderStatusOpen            OrderStatus = "OPEN"
+	OrderStatusPartiallyFilled OrderStatus = "PARTIALLY_FILLED"
+	OrderStatusClosed          OrderStatus = "CLOSED"
 )
 
 type OrderStatus string
@@ -19,9 +16,11 @@ func ParseOrderStatus(status string) (OrderStatus, error) {
 	switch status {
 	case "OPEN":
 		return OrderStatusOpen, nil
+	case "PARTIALLY_FILLED":
+		return OrderStatusPartiallyFilled, nil
 	case "CLOSED":
 		return OrderStatusClosed, nil
 	}
 
-	return "", fmt.Errorf("invalid order status")
+	return "", newError(ErrInvalidArgument, "invalid order status")
 }
@@ -1,9 +1,5 @@
 package main
 
-import (
-	"fmt"
-)
-
 const (
 	OrderTypeBuy  OrderType = "BUY"
 	OrderTypeSell OrderType = "SELL"
@@ -23,5 +19,5 @@ func ParseOrderType(_type string) (OrderType, error) {
 		return OrderTypeSell, nil
 	}
 
-	return "", fmt.Errorf("invalid order type")
+	return "", newError(ErrInvalidArgument, "invalid order type")
 }
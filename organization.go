@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -14,52 +14,124 @@ const (
 
 //Represents data stored in database
 //Contains the doctype
+//MSPID is the MSP the organization is bound to, only one organization can be bound to each MSP
+//AdminCertFingerprints optionally restricts changes to the organization to clients with one of the given certificates
+//Members lists the client identities registered as members of the organization, when it is not empty only members can act for the organization
+//A new organization stays PENDING until enough member organizations, whose MSP IDs are kept in Approvals, approve it
 type OrganizationInner struct {
 	Doc
 
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Address     string `json:"address"`
-	PhoneNumber string `json:"phone_number"`
+	ID                    string             `json:"id"`
+	Name                  string             `json:"name"`
+	Description           string             `json:"description"`
+	Address               string             `json:"address"`
+	PhoneNumber           string             `json:"phone_number"`
+	MSPID                 string             `json:"msp_id"`
+	AdminCertFingerprints []string           `json:"admin_cert_fingerprints,omitempty"`
+	Members               []string           `json:"members,omitempty"`
+	Status                OrganizationStatus `json:"status"`
+	Approvals             []string           `json:"approvals,omitempty"`
 }
 
 type Organization struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Address     string `json:"address"`
-	PhoneNumber string `json:"phone_number"`
+	ID                    string             `json:"id"`
+	Name                  string             `json:"name"`
+	Description           string             `json:"description"`
+	Address               string             `json:"address"`
+	PhoneNumber           string             `json:"phone_number"`
+	MSPID                 string             `json:"msp_id"`
+	AdminCertFingerprints []string           `json:"admin_cert_fingerprints,omitempty"`
+	Members               []string           `json:"members,omitempty"`
+	Status                OrganizationStatus `json:"status"`
+	Approvals             []string           `json:"approvals,omitempty"`
+	CreatedAt             time.Time          `json:"created_at"`
+	UpdatedAt             time.Time          `json:"updated_at"`
+	Archived              bool               `json:"archived,omitempty"`
+	ArchivedAt            time.Time          `json:"archived_at"`
+	Owner                 string             `json:"owner"`
 }
 
 //Parse organization from the data on the database
 func (s *SmartContract) FromOrganizationInner(_ contractapi.TransactionContextInterface, p *OrganizationInner) *Organization {
 	return &Organization{
-		ID:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		Address:     p.Address,
-		PhoneNumber: p.PhoneNumber,
+		ID:                    p.ID,
+		Name:                  p.Name,
+		Description:           p.Description,
+		Address:               p.Address,
+		PhoneNumber:           p.PhoneNumber,
+		MSPID:                 organizationMSPID(p),
+		AdminCertFingerprints: p.AdminCertFingerprints,
+		Members:               p.Members,
+		Status:                organizationStatus(p),
+		Approvals:             p.Approvals,
+		CreatedAt:             p.CreatedAt,
+		UpdatedAt:             p.UpdatedAt,
+		Archived:              p.Archived,
+		ArchivedAt:            p.ArchivedAt,
+		Owner:                 p.Owner,
 	}
 }
 
 func (s *SmartContract) GetOrganizationID(_ contractapi.TransactionContextInterface, id string) string {
-	return string(UnitDoc) + "_" + id
+	return string(OrganizationDoc) + "_" + id
+}
+
+//Returns the status of the organization
+func organizationStatus(o *OrganizationInner) OrganizationStatus {
+	if o.Status == "" {
+		return OrganizationStatusActive
+	}
+
+	return o.Status
+}
+
+//Returns the MSP ID the organization is bound to
+//Organizations registered before MSP IDs were recorded used their MSP ID as ID
+func organizationMSPID(o *OrganizationInner) string {
+	if o.MSPID != "" {
+		return o.MSPID
+	}
+
+	return o.ID
 }
 
 //Checks if organization with the given ID exists
 func (s *SmartContract) OrganizationExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetOrganizationID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
 	}
 
 	return assetJSON != nil, nil
 }
 
-//Creates a new organization with the given ID
+//Proposes a new organization with the given ID
 //User inputs the ID of the organization, the name of the organization, a description of the organization, the address of the organization and the phone number
+//The organization is bound to the MSP of the user, which must not be bound to another organization yet, and the user is registered as its first member
+//The organization stays PENDING until it is approved by the quorum of active organizations with ApproveOrganization
+//The first organization of the network has nobody to approve it and is active at once
+func (s *SmartContract) ProposeOrganization(ctx contractapi.TransactionContextInterface, id string, name string, description string, address string, phoneNumber string) error {
+	return s.createOrganization(ctx, id, name, description, address, phoneNumber, nil, nil)
+}
+
+//Same as ProposeOrganization
 func (s *SmartContract) CreateOrganization(ctx contractapi.TransactionContextInterface, id string, name string, description string, address string, phoneNumber string) error {
+	return s.createOrganization(ctx, id, name, description, address, phoneNumber, nil, nil)
+}
+
+func (s *SmartContract) createOrganization(ctx contractapi.TransactionContextInterface, id string, name string, description string, address string, phoneNumber string, fingerprints []string, members []string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("name", name, required, maxLength(MaxNameLength)),
+		field("description", description, maxLength(MaxDescriptionLength)),
+		field("address", address, isAddress),
+		field("phone_number", phoneNumber, isPhoneNumber),
+		field("admin_cert_fingerprints", fingerprints, each(isFingerprint)),
+		field("members", members, each(required, maxLength(MaxDescriptionLength))),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, OrganizationsCreate); err != nil {
 		return err
 	}
@@ -70,53 +142,78 @@ func (s *SmartContract) CreateOrganization(ctx contractapi.TransactionContextInt
 	}
 
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
-	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	boundID, err := s.getOrganizationIDByMSP(ctx, mspID)
 	if err != nil {
 		return err
 	}
 
-	doc := Doc{
-		Type:      UnitDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	if boundID != "" {
+		return newError(ErrAlreadyExists, "MSP %s is already bound to organization %s", mspID, boundID)
 	}
 
-	unit := OrganizationInner{
-		ID:          s.GetOrganizationID(ctx, id),
-		Name:        name,
-		Description: description,
-		Address:     address,
-		PhoneNumber: phoneNumber,
-		Doc:         doc,
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
 	}
 
-	assetBytes, err := json.Marshal(unit)
+	doc, err := s.newDoc(ctx, OrganizationDoc, clientID)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(unit.ID, assetBytes)
+	organization := OrganizationInner{
+		Doc:                   doc,
+		ID:                    id,
+		Name:                  name,
+		Description:           description,
+		Address:               address,
+		PhoneNumber:           phoneNumber,
+		MSPID:                 mspID,
+		AdminCertFingerprints: normalizeFingerprints(fingerprints),
+		Members:               addMember(members, clientID),
+		Status:                OrganizationStatusPending,
+	}
+
+	exists, err = s.hasOrganizations(ctx)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if !exists {
+		organization.Status = OrganizationStatusActive
+	}
+
+	if err := s.putOrganizationInner(ctx, &organization); err != nil {
+		return err
+	}
+
+	return s.setIndexEntry(ctx, OrganizationMSPIndex, []string{mspID, id}, true)
 }
 
 //Updates information regarding the organization
 //Updates the name, description, address and phone number of the organization with the given ID
+//Only clients of the MSP of the organization can update it, with one of its admin certificates when they are set, or admins
 func (s *SmartContract) UpdateOrganization(ctx contractapi.TransactionContextInterface, id string, name string, description string, address string, phoneNumber string) error {
-	if err := s.HasPermission(ctx, OrganizationsCreate); err != nil {
-		if innerErr := s.HasPermission(ctx, OrganizationsUpdate); innerErr != nil {
-			return innerErr
-		}
+	if err := validate(
+		field("id", id, isID),
+		field("name", name, required, maxLength(MaxNameLength)),
+		field("description", description, maxLength(MaxDescriptionLength)),
+		field("address", address, isAddress),
+		field("phone_number", phoneNumber, isPhoneNumber),
+	); err != nil {
+		return err
+	}
 
-		if orgID, innerErr := s.GetSubmittingClientOrganization(ctx); innerErr != nil && orgID != id {
-			return err
-		}
+	if err := s.HasPermission(ctx, OrganizationsUpdate); err != nil {
+		return err
 	}
 
 	exists, err := s.OrganizationExist(ctx, id)
@@ -125,7 +222,7 @@ func (s *SmartContract) UpdateOrganization(ctx contractapi.TransactionContextInt
 	}
 
 	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+		return newError(ErrNotFound, "the asset %s does not exist", id)
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -138,23 +235,17 @@ func (s *SmartContract) UpdateOrganization(ctx contractapi.TransactionContextInt
 		return err
 	}
 
+	if err := s.checkOrganizationAdmin(ctx, org); err != nil {
+		return err
+	}
+
 	org.Name = name
 	org.Description = description
 	org.Address = address
 	org.PhoneNumber = phoneNumber
 	org.UpdatedBy = clientID
 
-	assetBytes, err := json.Marshal(org)
-	if err != nil {
-		return err
-	}
-
-	err = ctx.GetStub().PutState(org.ID, assetBytes)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.putOrganizationInner(ctx, org)
 }
 
 //Returns OrganizationInner with the given ID
@@ -165,11 +256,11 @@ func (s *SmartContract) GetOrganizationInner(ctx contractapi.TransactionContextI
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetOrganizationID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var product OrganizationInner
@@ -190,11 +281,11 @@ func (s *SmartContract) GetOrganization(ctx contractapi.TransactionContextInterf
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetOrganizationID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var product OrganizationInner
@@ -213,9 +304,14 @@ func (s *SmartContract) GetAllOrganizations(ctx contractapi.TransactionContextIn
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s"}}`, OrganizationDoc))
+	query, err := activeDocsQuery(OrganizationDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets: %v", err)
+		return nil, newError(ErrInternal, "failed to get assets: %v", err)
 	}
 
 	defer results.Close()
@@ -239,15 +335,403 @@ func (s *SmartContract) GetAllOrganizations(ctx contractapi.TransactionContextIn
 	return assets, nil
 }
 
-//Deletes the organization from the system
+//Returns one page of all Organization
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllOrganizationsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*OrganizationsPage, error) {
+	if err := s.HasPermission(ctx, OrganizationsRead); err != nil {
+		return nil, err
+	}
+
+	query, err := activeDocsQuery(OrganizationDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &OrganizationsPage{Records: []*Organization{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var organization OrganizationInner
+		if err := json.Unmarshal(value, &organization); err != nil {
+			return err
+		}
+
+		organization.ID = strings.TrimPrefix(organization.ID, string(OrganizationDoc)+"_")
+		page.Records = append(page.Records, s.FromOrganizationInner(ctx, &organization))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
+//Deletes the organization with the given ID, refusing if other records still reference it
+//The organization is archived, it stays on the ledger and can be restored
 func (s *SmartContract) DeleteOrganization(ctx contractapi.TransactionContextInterface, id string) error {
+	return s.DeleteOrganizationWithMode(ctx, id, string(DeleteModeRestrict))
+}
+
+//Deletes the organization with the given ID, archiving it in both modes
+//In RESTRICT mode (the default) the organization is only archived when no other record references it
+//In ARCHIVE mode its open orders are closed and its pending offers withdrawn first
+//Documents of other organizations cannot be changed, the organization is not archived while they depend on it
+func (s *SmartContract) DeleteOrganizationWithMode(ctx contractapi.TransactionContextInterface, id string, modeInput string) error {
 	if err := s.HasPermission(ctx, OrganizationsDelete); err != nil {
 		return err
 	}
 
-	err := ctx.GetStub().DelState(s.GetOrganizationID(ctx, id))
+	mode, err := ParseDeleteMode(modeInput)
+	if err != nil {
+		return err
+	}
+
+	organization, err := s.GetOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationAdmin(ctx, organization); err != nil {
+		return err
+	}
+
+	if mode == DeleteModeRestrict {
+		if err := s.checkReferences(ctx, OrganizationDoc, id, organizationReferences); err != nil {
+			return err
+		}
+	} else {
+		orders, err := s.getReferencingOpenOrders(ctx, "organization_id", id)
+		if err != nil {
+			return err
+		}
+
+		offers, err := s.getPendingOrganizationOffers(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.cascadeArchive(ctx, OrganizationDoc, id, orders, offers); err != nil {
+			return err
+		}
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&organization.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	return s.putOrganizationInner(ctx, organization)
+}
+
+//Restores the archived organization with the given ID
+//Orders closed and offers withdrawn when the organization was archived stay that way
+func (s *SmartContract) RestoreOrganization(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, OrganizationsDelete); err != nil {
+		return err
+	}
+
+	organization, err := s.GetOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationAdmin(ctx, organization); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&organization.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putOrganizationInner(ctx, organization)
+}
+
+//Registers the client with the given ID as a member of the organization with the given ID
+func (s *SmartContract) AddOrganizationMember(ctx contractapi.TransactionContextInterface, id string, memberID string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("member_id", memberID, required, maxLength(MaxDescriptionLength)),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OrganizationsUpdate); err != nil {
+		return err
+	}
+
+	organization, err := s.GetOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationAdmin(ctx, organization); err != nil {
+		return err
+	}
+
+	for _, m := range organization.Members {
+		if m == memberID {
+			return newError(ErrConflict, "%s is already a member of organization %s", memberID, id)
+		}
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	organization.Members = append(organization.Members, memberID)
+	organization.UpdatedBy = clientID
+
+	return s.putOrganizationInner(ctx, organization)
+}
+
+//Removes the client with the given ID from the members of the organization with the given ID
+func (s *SmartContract) RemoveOrganizationMember(ctx contractapi.TransactionContextInterface, id string, memberID string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("member_id", memberID, required, maxLength(MaxDescriptionLength)),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OrganizationsUpdate); err != nil {
+		return err
+	}
+
+	organization, err := s.GetOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationAdmin(ctx, organization); err != nil {
+		return err
+	}
+
+	var members []string
+	for _, m := range organization.Members {
+		if m != memberID {
+			members = append(members, m)
+		}
+	}
+
+	if len(members) == len(organization.Members) {
+		return newError(ErrNotFound, "%s is not a member of organization %s", memberID, id)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	organization.Members = members
+	organization.UpdatedBy = clientID
+
+	return s.putOrganizationInner(ctx, organization)
+}
+
+//Replaces the admin certificate fingerprints of the organization with the given ID
+//User inputs the ID of the organization and the hex encoded SHA-256 fingerprints, an empty list lets every client of the MSP change the organization
+func (s *SmartContract) SetOrganizationAdminCertificates(ctx contractapi.TransactionContextInterface, id string, fingerprints []string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("fingerprints", fingerprints, each(isFingerprint)),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OrganizationsUpdate); err != nil {
+		return err
+	}
+
+	organization, err := s.GetOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationAdmin(ctx, organization); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	organization.AdminCertFingerprints = normalizeFingerprints(fingerprints)
+	organization.UpdatedBy = clientID
+
+	return s.putOrganizationInner(ctx, organization)
+}
+
+//Returns the Organization bound to the MSP of the current user
+func (s *SmartContract) GetMyOrganization(ctx contractapi.TransactionContextInterface) (*Organization, error) {
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	id, err := s.getOrganizationIDByMSP(ctx, mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		return nil, newError(ErrNotFound, "no organization is bound to MSP %s", mspID)
+	}
+
+	return s.GetOrganization(ctx, id)
+}
+
+//Checks that the current user can change the organization
+//The user must be a member of the organization and, when admin certificates are set, use one of them
+//Users holding the admin attribute can change any organization
+func (s *SmartContract) checkOrganizationAdmin(ctx contractapi.TransactionContextInterface, organization *OrganizationInner) error {
+	if err := s.checkOwner(ctx, organizationMSPID(organization)); err != nil {
+		return err
+	}
+
+	if len(organization.AdminCertFingerprints) == 0 {
+		return nil
+	}
+
+	fingerprint, err := getClientCertFingerprint(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range organization.AdminCertFingerprints {
+		if f == fingerprint {
+			return nil
+		}
+	}
+
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return newError(ErrForbidden, "unauthorized, only the admins of organization %s can change it", organization.ID)
+	}
+
+	return nil
+}
+
+//Returns the ID of the organization bound to the given MSP, or an empty string if there is none
+//Organizations registered before MSP IDs were recorded are not in the index, they are found under the MSP ID they used as ID
+func (s *SmartContract) getOrganizationIDByMSP(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	ids, err := s.getIndexedIDs(ctx, OrganizationMSPIndex, []string{mspID})
+	if err != nil {
+		return "", err
+	}
+
+	if len(ids) > 0 {
+		return ids[0], nil
+	}
+
+	assetBytes, err := ctx.GetStub().GetState(s.GetOrganizationID(ctx, mspID))
+	if err != nil {
+		return "", newError(ErrInternal, "failed to get asset %s: %v", mspID, err)
+	}
+
+	if assetBytes == nil {
+		return "", nil
+	}
+
+	var organization OrganizationInner
+	err = json.Unmarshal(assetBytes, &organization)
+	if err != nil {
+		return "", err
+	}
+
+	if organization.MSPID != "" && organization.MSPID != mspID {
+		return "", nil
+	}
+
+	return mspID, nil
+}
+
+//Returns the MSP ID the organization with the given ID is bound to
+//Reads the ledger directly, so documents can be created on behalf of an organization without the organizations.read attribute
+func (s *SmartContract) getOrganizationMSPID(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	organization, err := s.getOrganizationInner(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	return organizationMSPID(organization), nil
+}
+
+//Returns the OrganizationInner with the given ID
+//Does not check permissions
+func (s *SmartContract) getOrganizationInner(ctx contractapi.TransactionContextInterface, id string) (*OrganizationInner, error) {
+	assetBytes, err := ctx.GetStub().GetState(s.GetOrganizationID(ctx, id))
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
+	}
+
+	if assetBytes == nil {
+		return nil, newError(ErrNotFound, "organization %s does not exist", id)
+	}
+
+	var organization OrganizationInner
+	err = json.Unmarshal(assetBytes, &organization)
+	if err != nil {
+		return nil, err
+	}
+
+	organization.ID = strings.TrimPrefix(organization.ID, string(OrganizationDoc)+"_")
+	return &organization, nil
+}
+
+//Returns the fingerprints in lower case, as computed by getClientCertFingerprint
+func normalizeFingerprints(fingerprints []string) []string {
+	normalized := make([]string, 0, len(fingerprints))
+	for _, f := range fingerprints {
+		normalized = append(normalized, strings.ToLower(f))
+	}
+
+	return normalized
+}
+
+//Returns the members with the given client ID added, unless it is already one of them
+func addMember(members []string, clientID string) []string {
+	for _, m := range members {
+		if m == clientID {
+			return members
+		}
+	}
+
+	return append(members, clientID)
+}
+
+//Stores the given OrganizationInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetOrganizationInner
+func (s *SmartContract) putOrganizationInner(ctx contractapi.TransactionContextInterface, organization *OrganizationInner) error {
+	if err := s.touchDoc(ctx, &organization.Doc); err != nil {
+		return err
+	}
+
+	stored := *organization
+	stored.ID = s.GetOrganizationID(ctx, organization.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
 	if err != nil {
-		return fmt.Errorf("failed to delete asset %s: %v", id, err)
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
 	}
 
 	return nil
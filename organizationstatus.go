@@ -0,0 +1,24 @@
+package main
+
+const (
+	OrganizationStatusPending OrganizationStatus = "PENDING"
+	OrganizationStatusActive  OrganizationStatus = "ACTIVE"
+)
+
+//Organizations registered before the onboarding workflow have no status and are treated as ACTIVE
+type OrganizationStatus string
+
+func (o OrganizationStatus) String() string {
+	return string(o)
+}
+
+func ParseOrganizationStatus(status string) (OrganizationStatus, error) {
+	switch status {
+	case "PENDING":
+		return OrganizationStatusPending, nil
+	case "ACTIVE":
+		return OrganizationStatusActive, nil
+	}
+
+	return "", newError(ErrInvalidArgument, "invalid organization status")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Checks that the current user belongs to one of the given organizations, or holds the admin attribute
+//Called by every mutating function with the owner of the document being changed, or with the organization a new document is created for
+//The user must also be a member of the organization bound to its MSP, see checkMember
+//Units and products stored before owners were recorded have an empty owner and can only be changed by admins
+func (s *SmartContract) checkOwner(ctx contractapi.TransactionContextInterface, owners ...string) error {
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	for _, owner := range owners {
+		if owner != "" && owner == mspID {
+			return s.checkMember(ctx, mspID)
+		}
+	}
+
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return newError(ErrForbidden, "unauthorized, organization %s does not own the asset", mspID)
+	}
+
+	return nil
+}
+
+//Returns the given parties of a transaction or agreement, or every party when there are none and the current user holds the admin attribute
+func (s *SmartContract) withAdminParties(ctx contractapi.TransactionContextInterface, parties []TransactionParty) []TransactionParty {
+	if len(parties) > 0 {
+		return parties
+	}
+
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return parties
+	}
+
+	return []TransactionParty{TransactionPartyOrder, TransactionPartyTransaction}
+}
+
+//Checks that the current user is registered as a member of the organization bound to the given MSP, or holds the admin attribute
+//Organizations without members, such as those registered before members were recorded, accept every client of their MSP
+func (s *SmartContract) checkMember(ctx contractapi.TransactionContextInterface, mspID string) error {
+	id, err := s.getOrganizationIDByMSP(ctx, mspID)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		return nil
+	}
+
+	organization, err := s.getOrganizationInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(organization.Members) == 0 {
+		return nil
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range organization.Members {
+		if m == clientID {
+			return nil
+		}
+	}
+
+	if err := s.HasPermission(ctx, Admin); err != nil {
+		return newError(ErrForbidden, "unauthorized, the client is not a member of organization %s", id)
+	}
+
+	return nil
+}
+
+//Sets the owner of a document stored before owners were recorded to the MSP of the organization it belongs to
+//Called when orders, transactions, requests, offers and agreements are read, the owner is stored with the next change of the document
+//The owner stays empty when the organization does not exist anymore
+func (s *SmartContract) fillLegacyOwner(ctx contractapi.TransactionContextInterface, d *Doc, organizationID string) error {
+	if d.Owner != "" || organizationID == "" {
+		return nil
+	}
+
+	mspID, err := s.getOrganizationMSPID(ctx, organizationID)
+	if err != nil {
+		if e, ok := err.(*ContractError); ok && e.Code == ErrNotFound {
+			return nil
+		}
+
+		return err
+	}
+
+	d.Owner = mspID
+	return nil
+}
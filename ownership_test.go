@@ -0,0 +1,719 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+const testSalt = "0123456789abcdef"
+
+//MockStub with a minimal CouchDB query engine
+//Supports the selectors the contract sends while changing documents: equality, $in, $exists, $or and $elemMatch with $eq
+type testStub struct {
+	*shimtest.MockStub
+}
+
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	var q struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(s.State))
+	for key := range s.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	it := &testIterator{}
+	for _, key := range keys {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(s.State[key], &doc); err != nil {
+			continue
+		}
+
+		matched, err := matchSelector(doc, q.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			it.kvs = append(it.kvs, &queryresult.KV{Key: key, Value: s.State[key]})
+		}
+	}
+
+	return it, nil
+}
+
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for field, cond := range selector {
+		if field == "$or" {
+			branches, _ := cond.([]interface{})
+			matched := false
+			for _, branch := range branches {
+				branchSelector, _ := branch.(map[string]interface{})
+				ok, err := matchSelector(doc, branchSelector)
+				if err != nil {
+					return false, err
+				}
+				matched = matched || ok
+			}
+
+			if !matched {
+				return false, nil
+			}
+			continue
+		}
+
+		value, exists := doc[field]
+		ok, err := matchCondition(value, exists, cond)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchCondition(value interface{}, exists bool, cond interface{}) (bool, error) {
+	ops, ok := cond.(map[string]interface{})
+	if !ok {
+		return exists && value == cond, nil
+	}
+
+	for op, arg := range ops {
+		switch op {
+		case "$exists":
+			if exists != arg {
+				return false, nil
+			}
+		case "$eq":
+			if !exists || value != arg {
+				return false, nil
+			}
+		case "$in":
+			args, _ := arg.([]interface{})
+			found := false
+			for _, a := range args {
+				found = found || (exists && value == a)
+			}
+
+			if !found {
+				return false, nil
+			}
+		case "$elemMatch":
+			elems, _ := value.([]interface{})
+			found := false
+			for _, elem := range elems {
+				ok, err := matchCondition(elem, true, arg)
+				if err != nil {
+					return false, err
+				}
+				found = found || ok
+			}
+
+			if !found {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("unsupported operator %s", op)
+		}
+	}
+
+	return true, nil
+}
+
+type testIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *testIterator) HasNext() bool {
+	return len(it.kvs) > 0
+}
+
+func (it *testIterator) Next() (*queryresult.KV, error) {
+	if len(it.kvs) == 0 {
+		return nil, errors.New("no more results")
+	}
+
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+func (it *testIterator) Close() error {
+	return nil
+}
+
+//Serialized identity of a client of the given MSP holding the given attributes in its certificate
+func newTestIdentity(t *testing.T, mspID string, name string, attrs map[string]string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrBytes, err := json.Marshal(map[string]interface{}{"attrs": attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: name},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: attrmgr.AttrOID, Value: attrBytes}},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return creator
+}
+
+//Returns every access control attribute, with the admin attribute when admin is set
+func testAttributes(admin bool) map[string]string {
+	attrs := make(map[string]string)
+	for _, att := range attributes {
+		attrs[att.String()] = "true"
+	}
+
+	if admin {
+		attrs[Admin.String()] = "true"
+	}
+
+	return attrs
+}
+
+//Ledger shared by the ownership cases
+//Everything checked belongs to owner, counterparty is the other side of offers, transactions and agreements,
+//other is a client of an unrelated MSP and admin a client of another MSP holding the admin attribute
+type ownershipFixture struct {
+	t            *testing.T
+	contract     *SmartContract
+	stub         *testStub
+	clock        time.Time
+	owner        []byte
+	counterparty []byte
+	other        []byte
+	admin        []byte
+}
+
+//Returns a context for a call by the given identity, one second after the previous call
+func (f *ownershipFixture) as(identity []byte) contractapi.TransactionContextInterface {
+	f.t.Helper()
+
+	f.clock = f.clock.Add(time.Second)
+	f.stub.Creator = identity
+	f.stub.TxTimestamp = &timestamp.Timestamp{Seconds: f.clock.Unix()}
+	f.stub.TransientMap = nil
+
+	ci, err := cid.New(f.stub)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(f.stub)
+	ctx.SetClientIdentity(ci)
+	return ctx
+}
+
+//Passes a private price and its salt in the transient map of the next call
+func (f *ownershipFixture) setTransientPrice() {
+	f.stub.TransientMap = map[string][]byte{
+		TransientPriceKey: []byte(`{"amount":100,"exponent":2,"currency":"EUR"}`),
+		TransientSaltKey:  []byte(testSalt),
+	}
+}
+
+func (f *ownershipFixture) must(err error) {
+	f.t.Helper()
+
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+//Closes the open orders and withdraws the pending offers of the fixture, so the records they reference can be archived by any caller
+func (f *ownershipFixture) closeOpenRecords() {
+	f.t.Helper()
+
+	s := f.contract
+	f.must(s.CloseOrder(f.as(f.owner), "o1"))
+	f.must(s.CloseOrder(f.as(f.owner), "po1"))
+	f.must(s.CloseOrder(f.as(f.counterparty), "o2"))
+	f.must(s.WithdrawOffer(f.as(f.owner), "of1"))
+	f.must(s.WithdrawOffer(f.as(f.owner), "so1"))
+}
+
+func newOwnershipFixture(t *testing.T, owner, counterparty, other, admin []byte) *ownershipFixture {
+	t.Helper()
+
+	stub := &testStub{MockStub: shimtest.NewMockStub("ownership", nil)}
+	stub.MockTransactionStart("ownership")
+
+	f := &ownershipFixture{
+		t:            t,
+		contract:     &SmartContract{checkPermissions: true},
+		stub:         stub,
+		clock:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		owner:        owner,
+		counterparty: counterparty,
+		other:        other,
+		admin:        admin,
+	}
+	s := f.contract
+	price := Price{Amount: 100, Exponent: 2, Currency: "EUR"}
+
+	f.must(s.CreateOrganization(f.as(owner), "org1", "Organization 1", "", "", ""))
+	f.must(s.CreateOrganization(f.as(counterparty), "org2", "Organization 2", "", "", ""))
+	f.must(s.ApproveOrganization(f.as(owner), "org2"))
+	f.must(s.AddOrganizationMember(f.as(owner), "org1", "member2"))
+
+	f.must(s.CreateUnitWithDimension(f.as(owner), "kg", "Kilogram", "", 0, "MASS", "1"))
+	f.must(s.CreateProduct(f.as(owner), "p1", "Product", "", "kg"))
+
+	f.must(s.CreateOrder(f.as(owner), "o1", 10, 100, 2, "EUR", "SELL", "org1", "p1", "kg"))
+	ctx := f.as(owner)
+	f.setTransientPrice()
+	f.must(s.CreatePrivateOrder(ctx, "po1", 10, "SELL", "org1", "p1", "kg"))
+	f.must(s.CreateOrder(f.as(counterparty), "o2", 10, 100, 2, "EUR", "BUY", "org2", "p1", "kg"))
+	f.must(s.MakeTransaction(f.as(owner), "t1", 1, "org1", "o2"))
+
+	f.must(s.CreateRequest(f.as(owner), "r1", "Request 1"))
+	f.must(s.MakeOffer(f.as(counterparty), "of2", 100, "EUR", 2, "org2", "r1"))
+
+	f.must(s.CreateRequest(f.as(counterparty), "r2", "Request 2"))
+	f.must(s.MakeOffer(f.as(owner), "of1", 100, "EUR", 2, "org1", "r2"))
+
+	f.must(s.CreateSealedRequest(f.as(counterparty), "sr2", "Sealed request 2", 3600))
+	f.must(s.MakeSealedOffer(f.as(owner), "so1", NewOfferCommitment("so1", price, testSalt), "org1", "sr2", 0))
+
+	f.must(s.CreateRequest(f.as(owner), "r3", "Request 3"))
+	f.must(s.MakeOffer(f.as(counterparty), "of3", 100, "EUR", 2, "org2", "r3"))
+	f.must(s.AcceptOffer(f.as(owner), "r3", "of3", true))
+
+	return f
+}
+
+//Mutating transaction changing a document of the owner, or creating one on its behalf
+//setup prepares the ledger before the call
+type ownershipCase struct {
+	name  string
+	setup func(f *ownershipFixture)
+	call  func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error
+}
+
+//Transactions creating documents for the MSP of the user, and admin only ones, have no owner to check
+var ownershipCases = []ownershipCase{
+	{
+		name: "UpdateOrganization",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.UpdateOrganization(ctx, "org1", "Organization 1 renamed", "", "", "")
+		},
+	},
+	{
+		name: "UpdateOrganizationFromJSON",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.UpdateOrganizationFromJSON(ctx, OrganizationInput{ID: "org1", Name: "Organization 1 renamed"})
+		},
+	},
+	{
+		name: "DeleteOrganization",
+		setup: func(f *ownershipFixture) {
+			f.closeOpenRecords()
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteOrganizationWithMode(ctx, "org1", "ARCHIVE")
+		},
+	},
+	{
+		name: "RestoreOrganization",
+		setup: func(f *ownershipFixture) {
+			f.closeOpenRecords()
+			f.must(f.contract.DeleteOrganizationWithMode(f.as(f.owner), "org1", "ARCHIVE"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreOrganization(ctx, "org1")
+		},
+	},
+	{
+		name: "AddOrganizationMember",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.AddOrganizationMember(ctx, "org1", "member3")
+		},
+	},
+	{
+		name: "RemoveOrganizationMember",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RemoveOrganizationMember(ctx, "org1", "member2")
+		},
+	},
+	{
+		name: "SetOrganizationAdminCertificates",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.SetOrganizationAdminCertificates(ctx, "org1", nil)
+		},
+	},
+	{
+		name: "DeleteUnit",
+		setup: func(f *ownershipFixture) {
+			f.closeOpenRecords()
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteUnitWithMode(ctx, "kg", "ARCHIVE")
+		},
+	},
+	{
+		name: "RestoreUnit",
+		setup: func(f *ownershipFixture) {
+			f.closeOpenRecords()
+			f.must(f.contract.DeleteUnitWithMode(f.as(f.owner), "kg", "ARCHIVE"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreUnit(ctx, "kg")
+		},
+	},
+	{
+		name: "DeleteProduct",
+		setup: func(f *ownershipFixture) {
+			f.closeOpenRecords()
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteProductWithMode(ctx, "p1", "ARCHIVE")
+		},
+	},
+	{
+		name: "RestoreProduct",
+		setup: func(f *ownershipFixture) {
+			f.closeOpenRecords()
+			f.must(f.contract.DeleteProductWithMode(f.as(f.owner), "p1", "ARCHIVE"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreProduct(ctx, "p1")
+		},
+	},
+	{
+		name: "CreateOrder",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.CreateOrder(ctx, "o3", 10, 100, 2, "EUR", "SELL", "org1", "p1", "kg")
+		},
+	},
+	{
+		name: "CreateOrderFromJSON",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.CreateOrderFromJSON(ctx, OrderInput{
+				ID:             "o3",
+				Amount:         10,
+				Price:          Price{Amount: 100, Exponent: 2, Currency: "EUR"},
+				Type:           "SELL",
+				OrganizationID: "org1",
+				ProductID:      "p1",
+				UnitID:         "kg",
+			})
+		},
+	},
+	{
+		name: "CreatePrivateOrder",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			f.setTransientPrice()
+			return f.contract.CreatePrivateOrder(ctx, "po3", 10, "SELL", "org1", "p1", "kg")
+		},
+	},
+	{
+		name: "CloseOrder",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.CloseOrder(ctx, "o1")
+		},
+	},
+	{
+		name: "DeleteOrder",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteOrder(ctx, "o1")
+		},
+	},
+	{
+		name: "RestoreOrder",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.DeleteOrder(f.as(f.owner), "o1"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreOrder(ctx, "o1")
+		},
+	},
+	{
+		name: "ShareOrderPrice",
+		//The private price is only readable from the collections it was stored in, so the price is first shared with the organization of the admin
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.CreateOrganization(f.as(f.admin), "org4", "Organization 4", "", "", ""))
+			f.must(f.contract.ApproveOrganization(f.as(f.owner), "org4"))
+			f.must(f.contract.ApproveOrganization(f.as(f.counterparty), "org4"))
+			f.must(f.contract.ShareOrderPrice(f.as(f.owner), "po1", "org4"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.ShareOrderPrice(ctx, "po1", "org2")
+		},
+	},
+	{
+		name: "CloseRequest",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.CloseRequest(ctx, "r1")
+		},
+	},
+	{
+		name: "AcceptOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.AcceptOffer(ctx, "r1", "of2", true)
+		},
+	},
+	{
+		name: "DeleteRequest",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteRequest(ctx, "r1")
+		},
+	},
+	{
+		name: "RestoreRequest",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.DeleteRequest(f.as(f.owner), "r1"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreRequest(ctx, "r1")
+		},
+	},
+	{
+		name: "MakeOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.MakeOffer(ctx, "of4", 100, "EUR", 2, "org1", "r2")
+		},
+	},
+	{
+		name: "MakeOfferWithValidity",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.MakeOfferWithValidity(ctx, "of4", 100, "EUR", 2, "org1", "r2", 60)
+		},
+	},
+	{
+		name: "MakeOfferFromJSON",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.MakeOfferFromJSON(ctx, OfferInput{
+				ID:             "of4",
+				Value:          Price{Amount: 100, Exponent: 2, Currency: "EUR"},
+				OrganizationID: "org1",
+				RequestID:      "r2",
+			})
+		},
+	},
+	{
+		name: "MakePrivateOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			f.setTransientPrice()
+			return f.contract.MakePrivateOffer(ctx, "of4", "org1", "r2", 0)
+		},
+	},
+	{
+		name: "MakeSealedOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			commitment := NewOfferCommitment("so2", Price{Amount: 100, Exponent: 2, Currency: "EUR"}, testSalt)
+			return f.contract.MakeSealedOffer(ctx, "so2", commitment, "org1", "sr2", 0)
+		},
+	},
+	{
+		name: "WithdrawOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.WithdrawOffer(ctx, "of1")
+		},
+	},
+	{
+		name: "ReviseOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.ReviseOffer(ctx, "of1", 90, "EUR", 2, 0)
+		},
+	},
+	{
+		name: "ReviseOfferFromJSON",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.ReviseOfferFromJSON(ctx, OfferRevisionInput{ID: "of1", Value: Price{Amount: 90, Exponent: 2, Currency: "EUR"}})
+		},
+	},
+	{
+		name: "RevealOffer",
+		setup: func(f *ownershipFixture) {
+			f.clock = f.clock.Add(2 * time.Hour)
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RevealOffer(ctx, "so1", 100, "EUR", 2, testSalt)
+		},
+	},
+	{
+		name: "DeleteOffer",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteOffer(ctx, "of1")
+		},
+	},
+	{
+		name: "RestoreOffer",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.DeleteOffer(f.as(f.owner), "of1"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreOffer(ctx, "of1")
+		},
+	},
+	{
+		name: "MakeTransaction",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.MakeTransaction(ctx, "t2", 1, "org1", "o2")
+		},
+	},
+	{
+		name: "MakeTransactionInUnit",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.MakeTransactionInUnit(ctx, "t2", 1, "org1", "o2", "kg")
+		},
+	},
+	{
+		name: "MakeTransactionFromJSON",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.MakeTransactionFromJSON(ctx, TransactionInput{ID: "t2", Amount: 1, OrganizationID: "org1", OrderID: "o2", UnitID: "kg"})
+		},
+	},
+	{
+		name: "ChangeStatus",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.ChangeStatus(ctx, "t1", "CANCELED", "")
+		},
+	},
+	{
+		name: "DeleteTransaction",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.ChangeStatus(f.as(f.owner), "t1", "CANCELED", ""))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteTransaction(ctx, "t1")
+		},
+	},
+	{
+		name: "RestoreTransaction",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.ChangeStatus(f.as(f.owner), "t1", "CANCELED", ""))
+			f.must(f.contract.DeleteTransaction(f.as(f.owner), "t1"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreTransaction(ctx, "t1")
+		},
+	},
+	{
+		name: "ChangeAgreementStatus",
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.ChangeAgreementStatus(ctx, "r3", "CANCELED", "")
+		},
+	},
+	{
+		name: "DeleteAgreement",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.ChangeAgreementStatus(f.as(f.owner), "r3", "CANCELED", ""))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.DeleteAgreement(ctx, "r3")
+		},
+	},
+	{
+		name: "RestoreAgreement",
+		setup: func(f *ownershipFixture) {
+			f.must(f.contract.ChangeAgreementStatus(f.as(f.owner), "r3", "CANCELED", ""))
+			f.must(f.contract.DeleteAgreement(f.as(f.owner), "r3"))
+		},
+		call: func(f *ownershipFixture, ctx contractapi.TransactionContextInterface) error {
+			return f.contract.RestoreAgreement(ctx, "r3")
+		},
+	},
+}
+
+//Returns the code of an error returned by the contract
+func testErrorCode(err error) ErrorCode {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		return contractErr.Code
+	}
+
+	return ""
+}
+
+//Every mutating transaction is allowed to the MSP owning the document and to admins, and FORBIDDEN to any other MSP
+func TestOwnershipChecks(t *testing.T) {
+	owner := newTestIdentity(t, "Org1MSP", "user1", testAttributes(false))
+	counterparty := newTestIdentity(t, "Org2MSP", "user2", testAttributes(false))
+	other := newTestIdentity(t, "Org3MSP", "user3", testAttributes(false))
+	admin := newTestIdentity(t, "Org4MSP", "admin", testAttributes(true))
+
+	callers := []struct {
+		name      string
+		identity  []byte
+		forbidden bool
+	}{
+		{name: "owner", identity: owner},
+		{name: "other", identity: other, forbidden: true},
+		{name: "admin", identity: admin},
+	}
+
+	for _, c := range ownershipCases {
+		for _, caller := range callers {
+			c, caller := c, caller
+			t.Run(c.name+"/"+caller.name, func(t *testing.T) {
+				f := newOwnershipFixture(t, owner, counterparty, other, admin)
+				if c.setup != nil {
+					c.setup(f)
+				}
+
+				err := c.call(f, f.as(caller.identity))
+				if caller.forbidden {
+					if code := testErrorCode(err); code != ErrForbidden {
+						t.Fatalf("expected %s, got %v", ErrForbidden, err)
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("expected success, got %v", err)
+				}
+			})
+		}
+	}
+}
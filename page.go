@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Largest number of records a single page can hold
+const MaxPageSize = 200
+
+//Common fields of a page of query results
+//FetchedCount is the number of records in the page
+//Bookmark is passed to the next call to fetch the following page, it is empty once there are no more records
+type PageInfo struct {
+	FetchedCount int32  `json:"fetched_count"`
+	Bookmark     string `json:"bookmark"`
+}
+
+type UnitsPage struct {
+	PageInfo
+	Records []*Unit `json:"records"`
+}
+
+type ProductsPage struct {
+	PageInfo
+	Records []*Product `json:"records"`
+}
+
+type OrganizationsPage struct {
+	PageInfo
+	Records []*Organization `json:"records"`
+}
+
+type OrdersPage struct {
+	PageInfo
+	Records []*Order `json:"records"`
+}
+
+type RequestsPage struct {
+	PageInfo
+	Records []*Request `json:"records"`
+}
+
+type OffersPage struct {
+	PageInfo
+	Records []*Offer `json:"records"`
+}
+
+type TransactionsPage struct {
+	PageInfo
+	Records []*Transaction `json:"records"`
+}
+
+//Runs one page of the given query starting at the given bookmark (empty for the first page)
+//Calls fn with the value of every record in the page
+func (s *SmartContract) getQueryPage(ctx contractapi.TransactionContextInterface, query string, pageSize int32, bookmark string, fn func(value []byte) error) (PageInfo, error) {
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		return PageInfo{}, newError(ErrInvalidArgument, "invalid page size %d, it must be between 1 and %d", pageSize, MaxPageSize)
+	}
+
+	results, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return PageInfo{}, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return PageInfo{}, err
+		}
+
+		if err := fn(queryResult.Value); err != nil {
+			return PageInfo{}, err
+		}
+	}
+
+	info := PageInfo{
+		FetchedCount: metadata.GetFetchedRecordsCount(),
+		Bookmark:     metadata.GetBookmark(),
+	}
+
+	//CouchDB keeps returning the last bookmark, clear it so clients know they reached the end
+	if info.FetchedCount < pageSize {
+		info.Bookmark = ""
+	}
+
+	return info, nil
+}
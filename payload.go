@@ -0,0 +1,157 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Transactions taking a single JSON object instead of positional arguments
+//The payload is checked against the schema of the input struct in the contract metadata before it is decoded,
+//so unknown fields and missing required fields are rejected and generated clients get typed inputs
+
+//Payload of UpdateOrganizationFromJSON
+type OrganizationInput struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description" metadata:",optional"`
+	Address     string `json:"address" metadata:",optional"`
+	PhoneNumber string `json:"phone_number" metadata:",optional"`
+}
+
+//Payload of CreateOrganizationFromJSON
+//The certificate fingerprints and members can only be given at registration, they are changed later with their own transactions
+type OrganizationRegistrationInput struct {
+	OrganizationInput
+
+	AdminCertFingerprints []string `json:"admin_cert_fingerprints" metadata:",optional"`
+	Members               []string `json:"members" metadata:",optional"`
+}
+
+//Payload of CreateUnitFromJSON
+type UnitInput struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description" metadata:",optional"`
+	Exponent    uint32 `json:"exponent" metadata:",optional"`
+	Dimension   string `json:"dimension" metadata:",optional"`
+	Factor      string `json:"factor" metadata:",optional"`
+}
+
+//Payload of CreateProductFromJSON
+type ProductInput struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description" metadata:",optional"`
+	UnitIDs     []string `json:"unit_ids"`
+}
+
+//Payload of CreateOrderFromJSON
+type OrderInput struct {
+	ID             string `json:"id"`
+	Amount         uint32 `json:"amount"`
+	Price          Price  `json:"price"`
+	Type           string `json:"type"`
+	OrganizationID string `json:"organization_id"`
+	ProductID      string `json:"product_id"`
+	UnitID         string `json:"unit_id"`
+}
+
+//Payload of CreateRequestFromJSON
+//The bidding window is only allowed, and required, for sealed-bid requests
+type RequestInput struct {
+	ID            string `json:"id"`
+	Description   string `json:"description"`
+	Sealed        bool   `json:"sealed" metadata:",optional"`
+	BiddingWindow uint32 `json:"bidding_window" metadata:",optional"`
+}
+
+//Payload of MakeOfferFromJSON
+type OfferInput struct {
+	ID             string `json:"id"`
+	Value          Price  `json:"value"`
+	OrganizationID string `json:"organization_id"`
+	RequestID      string `json:"request_id"`
+	Validity       uint32 `json:"validity" metadata:",optional"`
+}
+
+//Payload of ReviseOfferFromJSON
+type OfferRevisionInput struct {
+	ID       string `json:"id"`
+	Value    Price  `json:"value"`
+	Validity uint32 `json:"validity" metadata:",optional"`
+}
+
+//Payload of MakeTransactionFromJSON
+type TransactionInput struct {
+	ID             string `json:"id"`
+	Amount         uint32 `json:"amount"`
+	OrganizationID string `json:"organization_id"`
+	OrderID        string `json:"order_id"`
+	UnitID         string `json:"unit_id" metadata:",optional"`
+}
+
+//Same as CreateOrganization, with optional admin certificate fingerprints and members
+func (s *SmartContract) CreateOrganizationFromJSON(ctx contractapi.TransactionContextInterface, payload OrganizationRegistrationInput) error {
+	return s.createOrganization(ctx, payload.ID, payload.Name, payload.Description, payload.Address, payload.PhoneNumber, payload.AdminCertFingerprints, payload.Members)
+}
+
+//Same as UpdateOrganization
+func (s *SmartContract) UpdateOrganizationFromJSON(ctx contractapi.TransactionContextInterface, payload OrganizationInput) error {
+	return s.UpdateOrganization(ctx, payload.ID, payload.Name, payload.Description, payload.Address, payload.PhoneNumber)
+}
+
+//Same as CreateUnitWithDimension, the dimension defaults to COUNT and the factor to 1 as in CreateUnit
+func (s *SmartContract) CreateUnitFromJSON(ctx contractapi.TransactionContextInterface, payload UnitInput) error {
+	dimension := payload.Dimension
+	if dimension == "" {
+		dimension = string(UnitDimensionCount)
+	}
+
+	factor := payload.Factor
+	if factor == "" {
+		factor = "1"
+	}
+
+	return s.CreateUnitWithDimension(ctx, payload.ID, payload.Name, payload.Description, payload.Exponent, dimension, factor)
+}
+
+//Same as CreateProduct, with the units given as a list
+func (s *SmartContract) CreateProductFromJSON(ctx contractapi.TransactionContextInterface, payload ProductInput) error {
+	return s.createProduct(ctx, payload.ID, payload.Name, payload.Description, payload.UnitIDs)
+}
+
+//Same as CreateOrder
+func (s *SmartContract) CreateOrderFromJSON(ctx contractapi.TransactionContextInterface, payload OrderInput) error {
+	return s.createOrder(ctx, payload.ID, payload.Amount, payload.Price, false, payload.Type, payload.OrganizationID, payload.ProductID, payload.UnitID)
+}
+
+//Same as CreateRequest, or CreateSealedRequest when sealed is set
+func (s *SmartContract) CreateRequestFromJSON(ctx contractapi.TransactionContextInterface, payload RequestInput) error {
+	if payload.Sealed {
+		return s.CreateSealedRequest(ctx, payload.ID, payload.Description, payload.BiddingWindow)
+	}
+
+	if payload.BiddingWindow != 0 {
+		return newError(ErrInvalidArgument, "only sealed-bid requests have a bidding window")
+	}
+
+	return s.CreateRequest(ctx, payload.ID, payload.Description)
+}
+
+//Same as MakeOfferWithValidity
+func (s *SmartContract) MakeOfferFromJSON(ctx contractapi.TransactionContextInterface, payload OfferInput) error {
+	return s.makeOffer(ctx, payload.ID, payload.Value, false, payload.OrganizationID, payload.RequestID, payload.Validity)
+}
+
+//Same as ReviseOffer
+func (s *SmartContract) ReviseOfferFromJSON(ctx contractapi.TransactionContextInterface, payload OfferRevisionInput) error {
+	return s.ReviseOffer(ctx, payload.ID, payload.Value.Amount, payload.Value.Currency, payload.Value.Exponent, payload.Validity)
+}
+
+//Same as MakeTransaction, or MakeTransactionInUnit when the unit is set
+func (s *SmartContract) MakeTransactionFromJSON(ctx contractapi.TransactionContextInterface, payload TransactionInput) error {
+	if payload.UnitID != "" {
+		return s.MakeTransactionInUnit(ctx, payload.ID, payload.Amount, payload.OrganizationID, payload.OrderID, payload.UnitID)
+	}
+
+	return s.MakeTransaction(ctx, payload.ID, payload.Amount, payload.OrganizationID, payload.OrderID)
+}
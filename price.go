@@ -1,11 +1,138 @@
 package main
 
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//Highest number of decimals a price can have
+//10^19 no longer fits in the amount
+const MaxPriceExponent = 18
+
 //Structure for storing monetary values
 //Amount represents how much money
 //Exponent represents how many decimals
 //Currency represents the type of currency (EUR,USD,...)
 type Price struct {
-	Amount   uint32 `json:"amount"`
+	Amount   uint64 `json:"amount"`
 	Exponent uint32 `json:"exponent"`
 	Currency string `json:"currency"`
 }
+
+//Returns the amount of the price with arbitrary precision, expressed with the given number of decimals
+//Fails if the price can't be expressed with that many decimals without losing precision
+func (p Price) scaledAmount(exponent uint32) (*big.Int, error) {
+	amount := new(big.Int).SetUint64(p.Amount)
+	if exponent >= p.Exponent {
+		return amount.Mul(amount, pow10(exponent-p.Exponent)), nil
+	}
+
+	quotient, remainder := new(big.Int).QuoRem(amount, pow10(p.Exponent-exponent), new(big.Int))
+	if remainder.Sign() != 0 {
+		return nil, newError(ErrInvalidArgument, "can't express %s with %d decimals without losing precision", p, exponent)
+	}
+
+	return quotient, nil
+}
+
+//Returns the price expressed with the given number of decimals
+//Fails if the amount overflows or precision would be lost
+func (p Price) Rescale(exponent uint32) (Price, error) {
+	amount, err := p.scaledAmount(exponent)
+	if err != nil {
+		return Price{}, err
+	}
+
+	return newPrice(amount, exponent, p.Currency)
+}
+
+//Compares two prices of the same currency, normalizing both to the same exponent
+//Returns -1 if p is lower than o, 0 if they are equal and 1 if p is higher than o
+func (p Price) Cmp(o Price) (int, error) {
+	if p.Currency != o.Currency {
+		return 0, newError(ErrInvalidArgument, "can't compare prices in %s and %s", p.Currency, o.Currency)
+	}
+
+	exponent := maxExponent(p, o)
+	a, _ := p.scaledAmount(exponent)
+	b, _ := o.scaledAmount(exponent)
+
+	return a.Cmp(b), nil
+}
+
+//Returns the sum of two prices of the same currency, expressed with the highest exponent of the two
+//Fails if the sum overflows
+func (p Price) Add(o Price) (Price, error) {
+	if p.Currency != o.Currency {
+		return Price{}, newError(ErrInvalidArgument, "can't add prices in %s and %s", p.Currency, o.Currency)
+	}
+
+	exponent := maxExponent(p, o)
+	a, _ := p.scaledAmount(exponent)
+	b, _ := o.scaledAmount(exponent)
+
+	return newPrice(a.Add(a, b), exponent, p.Currency)
+}
+
+//Returns the price multiplied by the given quantity, such as the total of a unit price for an order amount
+//Fails if the product overflows
+func (p Price) Mul(quantity uint64) (Price, error) {
+	amount := new(big.Int).SetUint64(p.Amount)
+	return newPrice(amount.Mul(amount, new(big.Int).SetUint64(quantity)), p.Exponent, p.Currency)
+}
+
+//Returns the price multiplied by the given ratio, such as when converting a price per unit to another unit
+//Decimals are added to keep the result exact, failing if that needs more than MaxPriceExponent decimals
+func (p Price) MulRat(ratio *big.Rat) (Price, error) {
+	r := new(big.Rat).Mul(new(big.Rat).SetUint64(p.Amount), ratio)
+	for exponent := p.Exponent; exponent <= MaxPriceExponent; exponent++ {
+		if r.IsInt() {
+			return newPrice(r.Num(), exponent, p.Currency)
+		}
+
+		r.Mul(r, big.NewRat(10, 1))
+	}
+
+	return Price{}, newError(ErrInvalidArgument, "can't express %s multiplied by %s exactly", p, ratio.RatString())
+}
+
+func (p Price) String() string {
+	digits := strconv.FormatUint(p.Amount, 10)
+	if p.Exponent == 0 {
+		return digits + " " + p.Currency
+	}
+
+	exponent := int(p.Exponent)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:] + " " + p.Currency
+}
+
+//Builds a price from an arbitrary precision amount
+//Fails if the amount does not fit in the price
+func newPrice(amount *big.Int, exponent uint32, currency string) (Price, error) {
+	if amount.Sign() < 0 || !amount.IsUint64() {
+		return Price{}, newError(ErrInvalidArgument, "price overflow")
+	}
+
+	return Price{
+		Amount:   amount.Uint64(),
+		Exponent: exponent,
+		Currency: currency,
+	}, nil
+}
+
+func maxExponent(a Price, b Price) uint32 {
+	if b.Exponent > a.Exponent {
+		return b.Exponent
+	}
+
+	return a.Exponent
+}
+
+func pow10(n uint32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func eur(amount uint64, exponent uint32) Price {
+	return Price{Amount: amount, Exponent: exponent, Currency: "EUR"}
+}
+
+//Prices with different exponents compare by value, and prices in different currencies can't be compared
+func TestPriceCmp(t *testing.T) {
+	cases := []struct {
+		name string
+		a    Price
+		b    Price
+		want int
+		code ErrorCode
+	}{
+		{name: "equal", a: eur(100, 2), b: eur(100, 2), want: 0},
+		{name: "equal with different exponents", a: eur(1, 0), b: eur(100, 2), want: 0},
+		{name: "lower", a: eur(99, 2), b: eur(1, 0), want: -1},
+		{name: "higher", a: eur(11, 1), b: eur(100, 2), want: 1},
+		{name: "exponent rescaled past uint64", a: eur(math.MaxUint64, 0), b: eur(1, MaxPriceExponent), want: 1},
+		{name: "different currencies", a: eur(1, 0), b: Price{Amount: 1, Currency: "USD"}, code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.a.Cmp(c.b)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if got != c.want {
+				t.Fatalf("expected %d, got %d", c.want, got)
+			}
+		})
+	}
+}
+
+//Sums are expressed with the highest exponent of the two prices and fail when they no longer fit in the amount
+func TestPriceAdd(t *testing.T) {
+	cases := []struct {
+		name string
+		a    Price
+		b    Price
+		want Price
+		code ErrorCode
+	}{
+		{name: "same exponent", a: eur(150, 2), b: eur(250, 2), want: eur(400, 2)},
+		{name: "rescaled to the highest exponent", a: eur(1, 0), b: eur(5, 3), want: eur(1005, 3)},
+		{name: "largest amount", a: eur(math.MaxUint64-1, 0), b: eur(1, 0), want: eur(math.MaxUint64, 0)},
+		{name: "overflow", a: eur(math.MaxUint64, 0), b: eur(1, 0), code: ErrInvalidArgument},
+		{name: "overflow when rescaling", a: eur(math.MaxUint64/10+1, 0), b: eur(1, 1), code: ErrInvalidArgument},
+		{name: "different currencies", a: eur(1, 0), b: Price{Amount: 1, Currency: "USD"}, code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.a.Add(c.b)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if got != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+//Multiplying by a quantity keeps the exponent and fails when the total no longer fits in the amount
+func TestPriceMul(t *testing.T) {
+	cases := []struct {
+		name     string
+		price    Price
+		quantity uint64
+		want     Price
+		code     ErrorCode
+	}{
+		{name: "quantity", price: eur(125, 2), quantity: 4, want: eur(500, 2)},
+		{name: "zero", price: eur(125, 2), quantity: 0, want: eur(0, 2)},
+		{name: "largest amount", price: eur(math.MaxUint64/3, 0), quantity: 3, want: eur(math.MaxUint64, 0)},
+		{name: "overflow", price: eur(math.MaxUint64/2+1, 0), quantity: 2, code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.price.Mul(c.quantity)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if got != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+//Multiplying by a ratio adds the decimals needed to keep the result exact, up to MaxPriceExponent
+func TestPriceMulRat(t *testing.T) {
+	cases := []struct {
+		name  string
+		price Price
+		ratio *big.Rat
+		want  Price
+		code  ErrorCode
+	}{
+		{name: "integer ratio", price: eur(250, 2), ratio: big.NewRat(1000, 1), want: eur(250000, 2)},
+		{name: "exact without new decimals", price: eur(300, 2), ratio: big.NewRat(1, 3), want: eur(100, 2)},
+		{name: "adds decimals", price: eur(1, 0), ratio: big.NewRat(1, 8), want: eur(125, 3)},
+		{name: "adds decimals up to the maximum", price: eur(1, 0), ratio: big.NewRat(1, 1000000000000000000), want: eur(1, MaxPriceExponent)},
+		{name: "needs more than the maximum decimals", price: eur(1, MaxPriceExponent), ratio: big.NewRat(1, 10), code: ErrInvalidArgument},
+		{name: "repeating decimals", price: eur(1, 0), ratio: big.NewRat(1, 3), code: ErrInvalidArgument},
+		{name: "overflow", price: eur(math.MaxUint64, 0), ratio: big.NewRat(2, 1), code: ErrInvalidArgument},
+		{name: "overflow while adding decimals", price: eur(math.MaxUint64, 0), ratio: big.NewRat(1, 2), code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.price.MulRat(c.ratio)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if got != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+//Rescaling adds decimals while the amount fits and only drops them when no precision is lost
+func TestPriceRescale(t *testing.T) {
+	cases := []struct {
+		name     string
+		price    Price
+		exponent uint32
+		want     Price
+		code     ErrorCode
+	}{
+		{name: "same exponent", price: eur(125, 2), exponent: 2, want: eur(125, 2)},
+		{name: "more decimals", price: eur(125, 2), exponent: 4, want: eur(12500, 4)},
+		{name: "fewer decimals", price: eur(12500, 4), exponent: 2, want: eur(125, 2)},
+		{name: "fewer decimals losing precision", price: eur(12501, 4), exponent: 2, code: ErrInvalidArgument},
+		{name: "maximum decimals", price: eur(1, 0), exponent: MaxPriceExponent, want: eur(1000000000000000000, MaxPriceExponent)},
+		{name: "overflow", price: eur(math.MaxUint64, 0), exponent: 1, code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.price.Rescale(c.exponent)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if got != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
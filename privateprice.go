@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	TransientPriceKey = "price"
+	TransientSaltKey  = "salt"
+	MinSaltLength     = 16
+)
+
+//Record stored in the private data collections
+//The salt is chosen by the client for every record, so the hash on the public ledger can't be matched by hashing guessed prices
+type privatePrice struct {
+	Price Price  `json:"price"`
+	Salt  string `json:"salt"`
+}
+
+//Returns the name of the implicit private data collection of the organization with the given MSP ID
+func getImplicitCollection(mspID string) string {
+	return "_implicit_org_" + mspID
+}
+
+//Reads the price passed as JSON in the "price" key of the transient map
+func getTransientPrice(ctx contractapi.TransactionContextInterface) (*Price, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to read transient map: %v", err)
+	}
+
+	priceBytes, ok := transient[TransientPriceKey]
+	if !ok {
+		return nil, newError(ErrInvalidArgument, "%s must be passed in the transient map", TransientPriceKey)
+	}
+
+	var p Price
+	err = json.Unmarshal(priceBytes, &p)
+	if err != nil {
+		return nil, newError(ErrInvalidArgument, "failed to parse %s from the transient map: %v", TransientPriceKey, err)
+	}
+
+	return &p, nil
+}
+
+//Reads the salt passed in the "salt" key of the transient map
+//The salt must be random and at least MinSaltLength bytes long
+func getTransientSalt(ctx contractapi.TransactionContextInterface) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", newError(ErrInternal, "failed to read transient map: %v", err)
+	}
+
+	salt, ok := transient[TransientSaltKey]
+	if !ok {
+		return "", newError(ErrInvalidArgument, "%s must be passed in the transient map", TransientSaltKey)
+	}
+
+	if len(salt) < MinSaltLength {
+		return "", newError(ErrInvalidArgument, "%s must be at least %d bytes long", TransientSaltKey, MinSaltLength)
+	}
+
+	return string(salt), nil
+}
+
+//Returns the hash of the stored representation of the price
+func hashPrice(priceBytes []byte) string {
+	sum := sha256.Sum256(priceBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+//Stores the price with the salt passed in the transient map under the given key in the implicit collection of every given organization
+//Returns the hash to keep on the public ledger
+func (s *SmartContract) putPrivatePrice(ctx contractapi.TransactionContextInterface, key string, price Price, orgIDs ...string) (string, error) {
+	salt, err := getTransientSalt(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	priceBytes, err := json.Marshal(privatePrice{Price: price, Salt: salt})
+	if err != nil {
+		return "", newError(ErrInternal, "failed to encode private price: %v", err)
+	}
+
+	if err := s.putPrivatePriceBytes(ctx, key, priceBytes, orgIDs...); err != nil {
+		return "", err
+	}
+
+	return hashPrice(priceBytes), nil
+}
+
+//Stores the given private price record under the given key in the implicit collection of every given organization
+func (s *SmartContract) putPrivatePriceBytes(ctx contractapi.TransactionContextInterface, key string, priceBytes []byte, orgIDs ...string) error {
+	for _, orgID := range orgIDs {
+		err := ctx.GetStub().PutPrivateData(getImplicitCollection(orgID), key, priceBytes)
+		if err != nil {
+			return newError(ErrInternal, "failed to store private price: %v", err)
+		}
+	}
+
+	return nil
+}
+
+//Returns the price stored under the given key if the submitting client belongs to one of the given organizations
+//The price is read from the collection of the client's organization and checked against the hash on the public ledger
+func (s *SmartContract) getPrivatePrice(ctx contractapi.TransactionContextInterface, key string, hash string, orgIDs ...string) (*Price, error) {
+	priceBytes, err := s.getPrivatePriceBytes(ctx, key, hash, orgIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var record privatePrice
+	err = json.Unmarshal(priceBytes, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	//Records stored before salts were required hold the price alone
+	if record.Salt == "" {
+		var p Price
+		err = json.Unmarshal(priceBytes, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		return &p, nil
+	}
+
+	return &record.Price, nil
+}
+
+//Returns the private price record stored under the given key if the submitting client belongs to one of the given organizations
+//The record is read from the collection of the client's organization and checked against the hash on the public ledger
+func (s *SmartContract) getPrivatePriceBytes(ctx contractapi.TransactionContextInterface, key string, hash string, orgIDs ...string) ([]byte, error) {
+	orgID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authorized := false
+	for _, id := range orgIDs {
+		if id == orgID {
+			authorized = true
+			break
+		}
+	}
+
+	if !authorized {
+		return nil, newError(ErrForbidden, "unauthorized")
+	}
+
+	priceBytes, err := ctx.GetStub().GetPrivateData(getImplicitCollection(orgID), key)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to read private price: %v", err)
+	}
+
+	if priceBytes == nil {
+		return nil, newError(ErrForbidden, "private price for %s is not available to %s", key, orgID)
+	}
+
+	if hashPrice(priceBytes) != hash {
+		return nil, newError(ErrConflict, "private price for %s does not match the public hash", key)
+	}
+
+	return priceBytes, nil
+}
+
+//Returns the price of the order with the given ID
+//Private prices are only returned to the organization of the order and the organizations it was shared with
+func (s *SmartContract) GetOrderPrice(ctx contractapi.TransactionContextInterface, id string) (*Price, error) {
+	order, err := s.GetOrderInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.PrivatePrice {
+		return &order.Price, nil
+	}
+
+	orgIDs := append([]string{order.Owner}, order.PriceSharedWith...)
+	return s.getPrivatePrice(ctx, s.GetOrderID(ctx, id), order.PriceHash, orgIDs...)
+}
+
+//Returns the value of the agreement with the given ID
+//Private values are only returned to the organizations of the offer and of the request
+func (s *SmartContract) GetAgreementPrice(ctx contractapi.TransactionContextInterface, id string) (*Price, error) {
+	agreement, err := s.GetAgreementInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !agreement.PrivateValue {
+		return &agreement.Value, nil
+	}
+
+	offerMSPID, requesterMSPID, err := s.getAgreementMSPIDs(ctx, agreement)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getPrivatePrice(ctx, s.GetAgreementID(ctx, id), agreement.ValueHash, offerMSPID, requesterMSPID)
+}
+
+//Shares the private price of the order with the given ID with the organization with the given ID
+//Only the organization of the order can share its price, usually with the counterparty of a transaction
+func (s *SmartContract) ShareOrderPrice(ctx contractapi.TransactionContextInterface, id string, organizationID string) error {
+	if err := s.HasPermission(ctx, OrdersUpdate); err != nil {
+		return err
+	}
+
+	order, err := s.GetOrderInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, order.Owner); err != nil {
+		return err
+	}
+
+	if !order.PrivatePrice {
+		return newError(ErrInvalidState, "order %s does not have a private price", id)
+	}
+
+	//Admins of another MSP can only share the price once their organization holds a copy
+	orgIDs := append([]string{order.Owner}, order.PriceSharedWith...)
+	priceBytes, err := s.getPrivatePriceBytes(ctx, s.GetOrderID(ctx, id), order.PriceHash, orgIDs...)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := s.getOrganizationMSPID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	for _, sharedWith := range order.PriceSharedWith {
+		if sharedWith == mspID {
+			return nil
+		}
+	}
+
+	//The record is copied as is, so it keeps matching the hash on the public ledger
+	if err := s.putPrivatePriceBytes(ctx, s.GetOrderID(ctx, id), priceBytes, mspID); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	order.PriceSharedWith = append(order.PriceSharedWith, mspID)
+	order.UpdatedBy = clientID
+
+	return s.putOrderInner(ctx, order)
+}
+
+//Returns the value of the offer with the given ID
+//Private values are only returned to the organization that made the offer and the organization of the request
+func (s *SmartContract) GetOfferPrice(ctx contractapi.TransactionContextInterface, id string) (*Price, error) {
+	offer, err := s.GetOfferInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !offer.PrivateValue {
+		return &offer.Value, nil
+	}
+
+	request, err := s.GetRequestInner(ctx, offer.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getPrivatePrice(ctx, s.GetOfferID(ctx, id), offer.ValueHash, offer.Owner, request.Owner)
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -24,10 +24,15 @@ type ProductInner struct {
 }
 
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Units       []*Unit `json:"units"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Units       []*Unit   `json:"units"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Archived    bool      `json:"archived,omitempty"`
+	ArchivedAt  time.Time `json:"archived_at"`
+	Owner       string    `json:"owner"`
 }
 
 //Parse product from the data on the database
@@ -47,6 +52,11 @@ func (s *SmartContract) FromProductInner(ctx contractapi.TransactionContextInter
 		Name:        p.Name,
 		Description: p.Description,
 		Units:       units,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		Archived:    p.Archived,
+		ArchivedAt:  p.ArchivedAt,
+		Owner:       p.Owner,
 	}
 }
 
@@ -58,7 +68,7 @@ func (s *SmartContract) GetProductID(_ contractapi.TransactionContextInterface,
 func (s *SmartContract) ProductExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetProductID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
 	}
 
 	return assetJSON != nil, nil
@@ -66,7 +76,26 @@ func (s *SmartContract) ProductExist(ctx contractapi.TransactionContextInterface
 
 //Creates a new product with the given ID
 //User inputs the id of the product, the name of the product, a description of the product, a list of units
+//The units are separated by ";"
 func (s *SmartContract) CreateProduct(ctx contractapi.TransactionContextInterface, id, name string, description string, unitsTemp string) error {
+	var units []string
+	if unitsTemp != "" {
+		units = strings.Split(unitsTemp, ";")
+	}
+
+	return s.createProduct(ctx, id, name, description, units)
+}
+
+func (s *SmartContract) createProduct(ctx contractapi.TransactionContextInterface, id string, name string, description string, units []string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("name", name, required, maxLength(MaxNameLength)),
+		field("description", description, maxLength(MaxDescriptionLength)),
+		field("units", units, required, each(isID)),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, ProductsCreate); err != nil {
 		return err
 	}
@@ -77,11 +106,9 @@ func (s *SmartContract) CreateProduct(ctx contractapi.TransactionContextInterfac
 	}
 
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
-	units := strings.Split(unitsTemp, ";")
-
 	if err := s.UnitsExist(ctx, units); err != nil {
 		return err
 	}
@@ -91,10 +118,9 @@ func (s *SmartContract) CreateProduct(ctx contractapi.TransactionContextInterfac
 		return err
 	}
 
-	doc := Doc{
-		Type:      ProductDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	doc, err := s.newDoc(ctx, ProductDoc, clientID)
+	if err != nil {
+		return err
 	}
 
 	unit := ProductInner{
@@ -107,15 +133,15 @@ func (s *SmartContract) CreateProduct(ctx contractapi.TransactionContextInterfac
 
 	assetBytes, err := json.Marshal(unit)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to encode asset %s: %v", unit.ID, err)
 	}
 
 	err = ctx.GetStub().PutState(unit.ID, assetBytes)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to put asset %s: %v", unit.ID, err)
 	}
 
-	return nil
+	return s.putReferenceIndexes(ctx, ProductDoc, id, assetBytes)
 }
 
 //Returns ProductInner with the given ID
@@ -126,11 +152,11 @@ func (s *SmartContract) GetProductInner(ctx contractapi.TransactionContextInterf
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetProductID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var product ProductInner
@@ -151,11 +177,11 @@ func (s *SmartContract) GetProduct(ctx contractapi.TransactionContextInterface,
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetProductID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var product ProductInner
@@ -174,9 +200,14 @@ func (s *SmartContract) GetAllProducts(ctx contractapi.TransactionContextInterfa
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s"}}`, ProductDoc))
+	query, err := activeDocsQuery(ProductDoc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets: %v", err)
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets: %v", err)
 	}
 
 	defer results.Close()
@@ -200,16 +231,145 @@ func (s *SmartContract) GetAllProducts(ctx contractapi.TransactionContextInterfa
 	return assets, nil
 }
 
-//Removes product from the system
+//Returns one page of all Product
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllProductsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*ProductsPage, error) {
+	if err := s.HasPermission(ctx, ProductsRead); err != nil {
+		return nil, err
+	}
+
+	query, err := activeDocsQuery(ProductDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ProductsPage{Records: []*Product{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var product ProductInner
+		if err := json.Unmarshal(value, &product); err != nil {
+			return err
+		}
+
+		product.ID = strings.TrimPrefix(product.ID, string(ProductDoc)+"_")
+		page.Records = append(page.Records, s.FromProductInner(ctx, &product))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
+//Deletes the product with the given ID, refusing if other records still reference it
+//The product is archived, it stays on the ledger and can be restored
 func (s *SmartContract) DeleteProduct(ctx contractapi.TransactionContextInterface, id string) error {
+	return s.DeleteProductWithMode(ctx, id, string(DeleteModeRestrict))
+}
+
+//Deletes the product with the given ID, archiving it in both modes
+//In RESTRICT mode (the default) the product is only archived when no other record references it
+//In ARCHIVE mode its open orders are closed first
+//Documents of other organizations cannot be changed, the product is not archived while they depend on it
+func (s *SmartContract) DeleteProductWithMode(ctx contractapi.TransactionContextInterface, id string, modeInput string) error {
+	if err := s.HasPermission(ctx, ProductsDelete); err != nil {
+		return err
+	}
+
+	mode, err := ParseDeleteMode(modeInput)
+	if err != nil {
+		return err
+	}
+
+	product, err := s.GetProductInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, product.Owner); err != nil {
+		return err
+	}
+
+	if mode == DeleteModeRestrict {
+		if err := s.checkReferences(ctx, ProductDoc, id, productReferences); err != nil {
+			return err
+		}
+	} else {
+		orders, err := s.getReferencingOpenOrders(ctx, "product_id", id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.cascadeArchive(ctx, ProductDoc, id, orders, nil); err != nil {
+			return err
+		}
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&product.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	return s.putProductInner(ctx, product)
+}
+
+//Restores the archived product with the given ID
+//Orders closed when the product was archived stay closed
+func (s *SmartContract) RestoreProduct(ctx contractapi.TransactionContextInterface, id string) error {
 	if err := s.HasPermission(ctx, ProductsDelete); err != nil {
 		return err
 	}
 
-	err := ctx.GetStub().DelState(s.GetProductID(ctx, id))
+	product, err := s.GetProductInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, product.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&product.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putProductInner(ctx, product)
+}
+
+//Stores the given ProductInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetProductInner
+func (s *SmartContract) putProductInner(ctx contractapi.TransactionContextInterface, product *ProductInner) error {
+	if err := s.touchDoc(ctx, &product.Doc); err != nil {
+		return err
+	}
+
+	stored := *product
+	stored.ID = s.GetProductID(ctx, product.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
 	if err != nil {
-		return fmt.Errorf("failed to delete asset %s: %v", id, err)
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
 	}
 
-	return nil
+	return s.putReferenceIndexes(ctx, ProductDoc, product.ID, assetBytes)
 }
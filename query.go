@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+//Every selector built here has a matching index in META-INF/statedb/couchdb/indexes
+//New queries must ship their index too, otherwise CouchDB scans every document
+
+//Selector matching every document of the given type that is not archived
+//User input must only be added as values of the returned map so it is always JSON encoded
+func activeSelector(docType DocType) map[string]interface{} {
+	return map[string]interface{}{
+		"doc_type": docType,
+		"archived": map[string]interface{}{"$exists": false},
+	}
+}
+
+//Encodes the selector and the optional sort as a CouchDB query
+func buildQuery(selector map[string]interface{}, sort []map[string]string) (string, error) {
+	query := map[string]interface{}{"selector": selector}
+	if len(sort) > 0 {
+		query["sort"] = sort
+	}
+
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryBytes), nil
+}
+
+//Query for every document of the given type that is not archived
+func activeDocsQuery(docType DocType) (string, error) {
+	return buildQuery(activeSelector(docType), nil)
+}
+
+//Query for the orders with the given status
+func ordersByStatusQuery(status OrderStatus) (string, error) {
+	selector := activeSelector(OrderDoc)
+	selector["status"] = status
+	return buildQuery(selector, nil)
+}
+
+//Query for the orders of the organization with the given ID
+func ordersByOrganizationQuery(org string) (string, error) {
+	selector := activeSelector(OrderDoc)
+	selector["organization_id"] = org
+	return buildQuery(selector, nil)
+}
+
+//Query for the orders of the organization with the given ID and the given status
+func ordersByOrganizationAndStatusQuery(org string, status OrderStatus) (string, error) {
+	selector := activeSelector(OrderDoc)
+	selector["organization_id"] = org
+	selector["status"] = status
+	return buildQuery(selector, nil)
+}
+
+//Query for the open and partially filled orders of the product and unit with the given IDs
+func openOrdersQuery(productID string, unitID string) (string, error) {
+	return buildQuery(map[string]interface{}{
+		"doc_type":   OrderDoc,
+		"product_id": productID,
+		"unit_id":    unitID,
+		"status":     map[string]interface{}{"$in": []OrderStatus{OrderStatusOpen, OrderStatusPartiallyFilled}},
+	}, nil)
+}
+
+//Query for the documents that store the given ID in the referencing field
+func referencingDocsQuery(ref reference, id string) (string, error) {
+	var condition interface{} = id
+	if ref.Array {
+		condition = map[string]interface{}{"$elemMatch": map[string]string{"$eq": id}}
+	}
+
+	return buildQuery(map[string]interface{}{
+		"doc_type": ref.DocType,
+		ref.Field:  condition,
+	}, nil)
+}
+
+//Query for the open and partially filled orders that store the given ID in the given field
+func openOrdersByReferenceQuery(field string, id string) (string, error) {
+	return buildQuery(map[string]interface{}{
+		"doc_type": OrderDoc,
+		field:      id,
+		"status":   map[string]interface{}{"$in": []OrderStatus{OrderStatusOpen, OrderStatusPartiallyFilled}},
+	}, nil)
+}
+
+//Query for the pending offers made by the organization with the given ID
+func pendingOffersByOrganizationQuery(organizationID string) (string, error) {
+	return buildQuery(map[string]interface{}{
+		"doc_type":        OfferDoc,
+		"organization_id": organizationID,
+		"status":          OfferStatusPending,
+	}, nil)
+}
+
+//Query for the requests with the given status
+func requestsByStatusQuery(status RequestStatus) (string, error) {
+	selector := activeSelector(RequestDoc)
+	selector["status"] = status
+	return buildQuery(selector, nil)
+}
+
+//Query for the offers made for the request with the given ID
+func offersForRequestQuery(requestID string) (string, error) {
+	selector := activeSelector(OfferDoc)
+	selector["request_id"] = requestID
+	return buildQuery(selector, nil)
+}
+
+//Query for the transactions of the order with the given ID, on either side
+func transactionsForOrderQuery(orderID string) (string, error) {
+	selector := activeSelector(TransactionDoc)
+	selector["$or"] = []map[string]interface{}{
+		{"order_id": orderID},
+		{"matched_order_id": orderID},
+	}
+	return buildQuery(selector, nil)
+}
+
+//Query for the archived documents of the given type
+func archivedDocsQuery(docType DocType) (string, error) {
+	return buildQuery(map[string]interface{}{
+		"doc_type": docType,
+		"archived": true,
+	}, nil)
+}
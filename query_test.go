@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const indexDir = "META-INF/statedb/couchdb/indexes"
+
+type couchIndex struct {
+	Name   string
+	Fields []string
+}
+
+func readIndexes(t *testing.T) []couchIndex {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(indexDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var indexes []couchIndex
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var def struct {
+			Index struct {
+				Fields []string `json:"fields"`
+			} `json:"index"`
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(b, &def); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+
+		if def.Type != "json" || len(def.Index.Fields) == 0 || def.Index.Fields[0] != "doc_type" {
+			t.Fatalf("%s: expected a json index starting with doc_type", file)
+		}
+
+		indexes = append(indexes, couchIndex{Name: def.Name, Fields: def.Index.Fields})
+	}
+
+	return indexes
+}
+
+//Splits a query into one set of selector fields per $or branch, and its sort fields
+//The archived $exists clause of activeSelector is left out, CouchDB can't use an index for it
+func queryFields(t *testing.T, query string) ([]map[string]bool, []string) {
+	t.Helper()
+
+	var q struct {
+		Selector map[string]json.RawMessage `json:"selector"`
+		Sort     []map[string]string        `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+
+	base := make(map[string]bool)
+	var branches []map[string]json.RawMessage
+	for field, value := range q.Selector {
+		switch {
+		case field == "$or":
+			if err := json.Unmarshal(value, &branches); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		case field == "archived" && strings.Contains(string(value), "$exists"):
+		default:
+			base[field] = true
+		}
+	}
+
+	var sortFields []string
+	for _, s := range q.Sort {
+		for field := range s {
+			sortFields = append(sortFields, field)
+		}
+	}
+
+	if len(branches) == 0 {
+		return []map[string]bool{base}, sortFields
+	}
+
+	var selectors []map[string]bool
+	for _, branch := range branches {
+		fields := make(map[string]bool)
+		for field := range base {
+			fields[field] = true
+		}
+		for field := range branch {
+			fields[field] = true
+		}
+		selectors = append(selectors, fields)
+	}
+
+	return selectors, sortFields
+}
+
+//Returns whether an index holds every field of the selector and of the sort, and nothing else
+func hasIndex(indexes []couchIndex, selector map[string]bool, sortFields []string) bool {
+	want := make(map[string]bool)
+	for field := range selector {
+		want[field] = true
+	}
+	for _, field := range sortFields {
+		want[field] = true
+	}
+
+	for _, index := range indexes {
+		if len(index.Fields) != len(want) {
+			continue
+		}
+
+		covered := true
+		for _, field := range index.Fields {
+			if !want[field] {
+				covered = false
+				break
+			}
+		}
+
+		if covered {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Every query the contract sends to CouchDB, by name
+func allQueries(t *testing.T) map[string]string {
+	t.Helper()
+
+	queries := make(map[string]string)
+	add := func(name string, query string, err error) {
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		queries[name] = query
+	}
+
+	docTypes := []DocType{UnitDoc, ProductDoc, OrganizationDoc, OrderDoc, TransactionDoc, RequestDoc, OfferDoc, AgreementDoc, RoleDoc}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	for _, docType := range docTypes {
+		q, err := activeDocsQuery(docType)
+		add("activeDocsQuery "+string(docType), q, err)
+
+		q, err = archivedDocsQuery(docType)
+		add("archivedDocsQuery "+string(docType), q, err)
+
+		q, err = getCreatedBetweenQuery(docType, from, to)
+		add("getCreatedBetweenQuery "+string(docType), q, err)
+	}
+
+	q, err := ordersByStatusQuery(OrderStatusOpen)
+	add("ordersByStatusQuery", q, err)
+
+	q, err = ordersByOrganizationQuery("org1")
+	add("ordersByOrganizationQuery", q, err)
+
+	q, err = ordersByOrganizationAndStatusQuery("org1", OrderStatusOpen)
+	add("ordersByOrganizationAndStatusQuery", q, err)
+
+	q, err = openOrdersQuery("product1", "unit1")
+	add("openOrdersQuery", q, err)
+
+	q, err = requestsByStatusQuery(RequestStatusOpen)
+	add("requestsByStatusQuery", q, err)
+
+	q, err = offersForRequestQuery("request1")
+	add("offersForRequestQuery", q, err)
+
+	q, err = transactionsForOrderQuery("order1")
+	add("transactionsForOrderQuery", q, err)
+
+	for _, refs := range [][]reference{unitReferences, productReferences, organizationReferences} {
+		for _, ref := range refs {
+			q, err = referencingDocsQuery(ref, "id1")
+			add(fmt.Sprintf("referencingDocsQuery %s %s", ref.DocType, ref.Field), q, err)
+		}
+	}
+
+	for _, field := range []string{"unit_id", "product_id", "organization_id"} {
+		q, err = openOrdersByReferenceQuery(field, "id1")
+		add("openOrdersByReferenceQuery "+field, q, err)
+	}
+
+	q, err = pendingOffersByOrganizationQuery("org1")
+	add("pendingOffersByOrganizationQuery", q, err)
+
+	//Search accepts the document types that have a read attribute
+	for docType := range docReadAttributes {
+		fields := make(map[string]fieldKind)
+		for field, kind := range docSearchFields {
+			fields[field] = kind
+		}
+		for field, kind := range searchFields[docType] {
+			fields[field] = kind
+		}
+
+		for field, kind := range fields {
+			var value string
+			switch kind {
+			case fieldString:
+				value = `"x"`
+			case fieldNumber:
+				value = `1`
+			case fieldTime:
+				value = `"2024-01-01T00:00:00Z"`
+			case fieldBool:
+				value = `true`
+			}
+
+			selector, err := buildSearchSelector(docType, fmt.Sprintf(`{%q:%s}`, field, value))
+			if err != nil {
+				t.Fatalf("Search %s by %s: %v", docType, field, err)
+			}
+
+			q, err := buildQuery(selector, nil)
+			add(fmt.Sprintf("Search %s by %s", docType, field), q, err)
+
+			if kind != fieldNumber && kind != fieldTime {
+				continue
+			}
+
+			sortFields, err := buildSearchSort(docType, "-"+field)
+			if err != nil {
+				t.Fatalf("Search %s sorted by %s: %v", docType, field, err)
+			}
+
+			q, err = buildQuery(activeSelector(docType), sortFields)
+			add(fmt.Sprintf("Search %s sorted by %s", docType, field), q, err)
+		}
+	}
+
+	return queries
+}
+
+//Each query must have an index on exactly its selector and sort fields, otherwise CouchDB scans every document of the type or rejects the sort
+func TestQueriesHaveIndexes(t *testing.T) {
+	indexes := readIndexes(t)
+	queries := allQueries(t)
+
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		selectors, sortFields := queryFields(t, queries[name])
+		for _, selector := range selectors {
+			if !hasIndex(indexes, selector, sortFields) {
+				fields := make([]string, 0, len(selector))
+				for field := range selector {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+
+				t.Errorf("%s: no index on %v sorted by %v", name, fields, sortFields)
+			}
+		}
+	}
+}
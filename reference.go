@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Field of a document that stores the ID of another document
+//Array is set when the field stores a list of IDs
+type reference struct {
+	DocType DocType
+	Field   string
+	Array   bool
+}
+
+//Documents that reference a unit
+var unitReferences = []reference{
+	{DocType: ProductDoc, Field: "unit_ids", Array: true},
+	{DocType: OrderDoc, Field: "unit_id"},
+}
+
+//Documents that reference a product
+var productReferences = []reference{
+	{DocType: OrderDoc, Field: "product_id"},
+}
+
+//Documents that reference an organization
+var organizationReferences = []reference{
+	{DocType: OrderDoc, Field: "organization_id"},
+	{DocType: OfferDoc, Field: "organization_id"},
+	{DocType: TransactionDoc, Field: "organization_id"},
+	{DocType: RequestDoc, Field: "organization_id"},
+	{DocType: AgreementDoc, Field: "organization_id"},
+	{DocType: AgreementDoc, Field: "requester_organization_id"},
+}
+
+//Every field of a document that stores the ID of another record
+var allReferences = [][]reference{unitReferences, productReferences, organizationReferences}
+
+//Adds the document of the given type with the given ID to the reference index, under every ID stored in its referencing fields
+//Called with the stored representation each time the document is written, entries already in place are written again with the same value
+//The referencing fields never change once a document is created and documents are archived instead of deleted, so entries are never removed
+func (s *SmartContract) putReferenceIndexes(ctx contractapi.TransactionContextInterface, docType DocType, id string, assetBytes []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(assetBytes, &fields); err != nil {
+		return err
+	}
+
+	for _, refs := range allReferences {
+		for _, ref := range refs {
+			value, ok := fields[ref.Field]
+			if ref.DocType != docType || !ok {
+				continue
+			}
+
+			var targets []string
+			if ref.Array {
+				if err := json.Unmarshal(value, &targets); err != nil {
+					return err
+				}
+			} else {
+				var target string
+				if err := json.Unmarshal(value, &target); err != nil {
+					return err
+				}
+
+				targets = []string{target}
+			}
+
+			for _, target := range targets {
+				if target == "" {
+					continue
+				}
+
+				if err := s.setIndexEntry(ctx, ReferenceIndex, []string{string(docType), ref.Field, target, id}, true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+//Returns the stored documents that store the given ID in the referencing field
+//Read from the reference index when composite indexes are enabled, which also works on LevelDB and protects the result against phantom reads,
+//and with the given CouchDB query, selecting the same documents or a part of them, otherwise
+func (s *SmartContract) getReferencingValues(ctx contractapi.TransactionContextInterface, ref reference, id string, query string) ([][]byte, error) {
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var values [][]byte
+	if indexed {
+		ids, err := s.getIndexedIDs(ctx, ReferenceIndex, []string{string(ref.DocType), ref.Field, id})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, refID := range ids {
+			key := string(ref.DocType) + "_" + refID
+			value, err := ctx.GetStub().GetState(key)
+			if err != nil {
+				return nil, newError(ErrInternal, "failed to get asset %s:%v", key, err)
+			}
+
+			if value != nil {
+				values = append(values, value)
+			}
+		}
+
+		return values, nil
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, queryResult.Value)
+	}
+
+	return values, nil
+}
+
+//Returns the IDs of the documents that store the given ID in the referencing field
+func (s *SmartContract) getReferencingIDs(ctx contractapi.TransactionContextInterface, ref reference, id string) ([]string, error) {
+	query, err := referencingDocsQuery(ref, id)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := s.getReferencingValues(ctx, ref, id, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, value := range values {
+		var d struct {
+			ID string `json:"id"`
+		}
+		err = json.Unmarshal(value, &d)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, strings.TrimPrefix(d.ID, string(ref.DocType)+"_"))
+	}
+
+	return ids, nil
+}
+
+//Checks that no document references the record with the given ID
+//Returns an error listing every referencing document otherwise
+func (s *SmartContract) checkReferences(ctx contractapi.TransactionContextInterface, docType DocType, id string, refs []reference) error {
+	var blockers []string
+	for _, ref := range refs {
+		ids, err := s.getReferencingIDs(ctx, ref, id)
+		if err != nil {
+			return err
+		}
+
+		for _, refID := range ids {
+			blockers = append(blockers, fmt.Sprintf("%s %s", ref.DocType, refID))
+		}
+	}
+
+	if len(blockers) > 0 {
+		return newError(ErrConflict, "%s %s is still referenced by %s", docType, id, strings.Join(blockers, ", "))
+	}
+
+	return nil
+}
+
+//Returns the open and partially filled orders that store the given ID in the given field
+func (s *SmartContract) getReferencingOpenOrders(ctx contractapi.TransactionContextInterface, field string, id string) ([]*OrderInner, error) {
+	query, err := openOrdersByReferenceQuery(field, id)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := s.getReferencingValues(ctx, reference{DocType: OrderDoc, Field: field}, id, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []*OrderInner
+	for _, value := range values {
+		var order OrderInner
+		err = json.Unmarshal(value, &order)
+		if err != nil {
+			return nil, err
+		}
+
+		//The reference index lists the orders of every status
+		if order.Status != OrderStatusOpen && order.Status != OrderStatusPartiallyFilled {
+			continue
+		}
+
+		order.ID = strings.TrimPrefix(order.ID, string(OrderDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, &order)
+	}
+
+	return orders, nil
+}
+
+//Returns the pending offers made by the organization with the given ID
+func (s *SmartContract) getPendingOrganizationOffers(ctx contractapi.TransactionContextInterface, organizationID string) ([]*OfferInner, error) {
+	query, err := pendingOffersByOrganizationQuery(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := s.getReferencingValues(ctx, reference{DocType: OfferDoc, Field: "organization_id"}, organizationID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var offers []*OfferInner
+	for _, value := range values {
+		var offer OfferInner
+		err = json.Unmarshal(value, &offer)
+		if err != nil {
+			return nil, err
+		}
+
+		offer.ID = strings.TrimPrefix(offer.ID, string(OfferDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &offer.Doc, offer.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		if err := s.resolveOfferStatus(ctx, &offer); err != nil {
+			return nil, err
+		}
+
+		if offer.Status == OfferStatusPending {
+			offers = append(offers, &offer)
+		}
+	}
+
+	return offers, nil
+}
+
+//Closes the given orders and withdraws the given offers, which depend on the record with the given ID being archived
+//Only documents of the organization of the current user are changed, their key-level endorsement policies would reject the change otherwise
+//Returns an error listing the documents of other organizations when there are any, leaving every document as it is
+func (s *SmartContract) cascadeArchive(ctx contractapi.TransactionContextInterface, docType DocType, id string, orders []*OrderInner, offers []*OfferInner) error {
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	var blockers []string
+	for _, order := range orders {
+		if order.Owner != mspID {
+			blockers = append(blockers, fmt.Sprintf("%s %s", OrderDoc, order.ID))
+		}
+	}
+
+	for _, offer := range offers {
+		if offer.Owner != mspID {
+			blockers = append(blockers, fmt.Sprintf("%s %s", OfferDoc, offer.ID))
+		}
+	}
+
+	if len(blockers) > 0 {
+		return newError(ErrConflict, "%s %s is still referenced by %s", docType, id, strings.Join(blockers, ", "))
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		order.Status = OrderStatusClosed
+		order.UpdatedBy = clientID
+		if err := s.putOrderInner(ctx, order); err != nil {
+			return err
+		}
+	}
+
+	for _, offer := range offers {
+		offer.Status = OfferStatusWithdrawn
+		offer.UpdatedBy = clientID
+		if err := s.putOfferInner(ctx, offer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+)
+
+//Deleting a referenced record refuses in RESTRICT mode, and in ARCHIVE mode only closes the open orders of the current organization
+//References are read with CouchDB queries and from the reference index alike
+func TestDeleteModes(t *testing.T) {
+	owner := newTestIdentity(t, "Org1MSP", "user1", testAttributes(false))
+	counterparty := newTestIdentity(t, "Org2MSP", "user2", testAttributes(false))
+	other := newTestIdentity(t, "Org3MSP", "user3", testAttributes(false))
+	admin := newTestIdentity(t, "Org4MSP", "admin", testAttributes(true))
+
+	cases := []struct {
+		name     string
+		setup    func(f *ownershipFixture)
+		mode     string
+		code     ErrorCode
+		archived bool
+		closed   []string
+		open     []string
+	}{
+		{name: "default refuses referenced unit", code: ErrConflict, open: []string{"o1", "o2"}},
+		{name: "restrict refuses referenced unit", mode: "RESTRICT", code: ErrConflict, open: []string{"o1", "o2"}},
+		{name: "archive refuses orders of other organizations", mode: "ARCHIVE", code: ErrConflict, open: []string{"o1", "po1", "o2"}},
+		{
+			name: "archive closes own orders",
+			setup: func(f *ownershipFixture) {
+				f.must(f.contract.CloseOrder(f.as(f.counterparty), "o2"))
+			},
+			mode:     "ARCHIVE",
+			archived: true,
+			closed:   []string{"o1", "po1"},
+		},
+		{name: "invalid mode", mode: "CASCADE", code: ErrInvalidArgument, open: []string{"o1"}},
+	}
+
+	for _, c := range cases {
+		for _, indexed := range []bool{false, true} {
+			c, indexed := c, indexed
+			name := c.name
+			if indexed {
+				name += "/composite indexes"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				f := newOwnershipFixture(t, owner, counterparty, other, admin)
+				f.must(f.contract.SetCompositeIndexes(f.as(admin), indexed))
+				if c.setup != nil {
+					c.setup(f)
+				}
+
+				err := f.contract.DeleteUnitWithMode(f.as(f.owner), "kg", c.mode)
+				if code := testErrorCode(err); code != c.code {
+					t.Fatalf("expected %q, got %v", c.code, err)
+				}
+
+				unit, err := f.contract.GetUnitInner(f.as(f.owner), "kg")
+				f.must(err)
+				if unit.Archived != c.archived {
+					t.Fatalf("expected archived %v, got %v", c.archived, unit.Archived)
+				}
+
+				for _, id := range c.closed {
+					order, err := f.contract.GetOrderInner(f.as(f.owner), id)
+					f.must(err)
+					if order.Status != OrderStatusClosed {
+						t.Fatalf("expected order %s to be closed, got %s", id, order.Status)
+					}
+				}
+
+				for _, id := range c.open {
+					order, err := f.contract.GetOrderInner(f.as(f.owner), id)
+					f.must(err)
+					if order.Status == OrderStatusClosed {
+						t.Fatalf("expected order %s to stay open", id)
+					}
+				}
+			})
+		}
+	}
+}
+
+//A record nothing references is archived in RESTRICT mode and stays on the ledger
+func TestDeleteUnreferencedUnit(t *testing.T) {
+	owner := newTestIdentity(t, "Org1MSP", "user1", testAttributes(false))
+	counterparty := newTestIdentity(t, "Org2MSP", "user2", testAttributes(false))
+	other := newTestIdentity(t, "Org3MSP", "user3", testAttributes(false))
+	admin := newTestIdentity(t, "Org4MSP", "admin", testAttributes(true))
+
+	f := newOwnershipFixture(t, owner, counterparty, other, admin)
+	f.must(f.contract.CreateUnitWithDimension(f.as(owner), "l", "Litre", "", 0, "VOLUME", "1"))
+	f.must(f.contract.DeleteUnit(f.as(owner), "l"))
+
+	unit, err := f.contract.GetUnitInner(f.as(owner), "l")
+	f.must(err)
+	if !unit.Archived {
+		t.Fatal("expected the unit to be archived")
+	}
+
+	f.must(f.contract.RestoreUnit(f.as(owner), "l"))
+}
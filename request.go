@@ -2,41 +2,77 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 const (
-	RequestDoc DocType = "request"
+	RequestDoc            DocType = "request"
+	OfferAcceptedEventKey         = "offer_accepted"
 )
 
 //Represents data stored in database
 //Contains the doctype
+//BiddingClosesAtUTC stores the end of the bidding window in SortableTimeLayout for queries, it is omitted for open requests
 type RequestInner struct {
 	Doc
 
-	ID          string        `json:"id"`
-	Description string        `json:"description"`
-	Status      RequestStatus `json:"status"`
+	ID                 string        `json:"id"`
+	Description        string        `json:"description"`
+	Status             RequestStatus `json:"status"`
+	AcceptedOfferID    string        `json:"accepted_offer_id,omitempty"`
+	Sealed             bool          `json:"sealed"`
+	BiddingClosesAt    time.Time     `json:"bidding_closes_at"`
+	BiddingClosesAtUTC string        `json:"bidding_closes_at_utc,omitempty"`
+	OrganizationID     string        `json:"organization_id"`
 }
 
 type Request struct {
-	ID          string        `json:"id"`
-	Description string        `json:"description"`
-	Status      RequestStatus `json:"status"`
+	ID              string        `json:"id"`
+	Description     string        `json:"description"`
+	Status          RequestStatus `json:"status"`
+	AcceptedOfferID string        `json:"accepted_offer_id,omitempty"`
+	Sealed          bool          `json:"sealed"`
+	BiddingClosesAt time.Time     `json:"bidding_closes_at"`
+	OrganizationID  string        `json:"organization_id"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	Archived        bool          `json:"archived,omitempty"`
+	ArchivedAt      time.Time     `json:"archived_at"`
+	Owner           string        `json:"owner"`
 }
 
 //Parse request from the data on the database
 func (s *SmartContract) FromRequestInner(ctx contractapi.TransactionContextInterface, p *RequestInner) *Request {
 	return &Request{
-		ID:          p.ID,
-		Description: p.Description,
-		Status:      p.Status,
+		ID:              p.ID,
+		Description:     p.Description,
+		Status:          p.Status,
+		AcceptedOfferID: p.AcceptedOfferID,
+		Sealed:          p.Sealed,
+		BiddingClosesAt: p.BiddingClosesAt,
+		OrganizationID:  p.OrganizationID,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		Archived:        p.Archived,
+		ArchivedAt:      p.ArchivedAt,
+		Owner:           p.Owner,
 	}
 }
 
+type OfferAcceptedEvent struct {
+	RequestID      string `json:"request_id"`
+	OfferID        string `json:"offer_id"`
+	OrganizationID string `json:"organization_id"`
+}
+
+func NewOfferAcceptedEvent(requestID string, offerID string, organizationID string) ([]byte, error) {
+	return json.Marshal(OfferAcceptedEvent{RequestID: requestID, OfferID: offerID, OrganizationID: organizationID})
+}
+
 func (s *SmartContract) GetRequestID(ctx contractapi.TransactionContextInterface, id string) string {
 	return string(RequestDoc) + "_" + id
 }
@@ -45,7 +81,7 @@ func (s *SmartContract) GetRequestID(ctx contractapi.TransactionContextInterface
 func (s *SmartContract) RequestExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetRequestID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
 	}
 
 	return assetJSON != nil, nil
@@ -54,6 +90,28 @@ func (s *SmartContract) RequestExist(ctx contractapi.TransactionContextInterface
 //Creates a new request with the given ID
 //User inputs the ID of the request and a description of the project being presented
 func (s *SmartContract) CreateRequest(ctx contractapi.TransactionContextInterface, id string, description string) error {
+	return s.createRequest(ctx, id, description, false, 0)
+}
+
+//Creates a new sealed-bid request with the given ID
+//Offers for a sealed-bid request only store a commitment until the bidding window closes, after which they must be revealed
+//User inputs the ID of the request, a description of the project being presented and for how many seconds the bidding window stays open
+func (s *SmartContract) CreateSealedRequest(ctx contractapi.TransactionContextInterface, id string, description string, biddingWindow uint32) error {
+	if biddingWindow == 0 {
+		return newError(ErrInvalidArgument, "sealed-bid requests need a bidding window")
+	}
+
+	return s.createRequest(ctx, id, description, true, biddingWindow)
+}
+
+func (s *SmartContract) createRequest(ctx contractapi.TransactionContextInterface, id string, description string, sealed bool, biddingWindow uint32) error {
+	if err := validate(
+		field("id", id, isID),
+		field("description", description, required, maxLength(MaxDescriptionLength)),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, RequestsCreate); err != nil {
 		return err
 	}
@@ -63,7 +121,7 @@ func (s *SmartContract) CreateRequest(ctx contractapi.TransactionContextInterfac
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -71,30 +129,57 @@ func (s *SmartContract) CreateRequest(ctx contractapi.TransactionContextInterfac
 		return err
 	}
 
-	doc := Doc{
-		Type:      RequestDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+
+	//Requests are made by the organization bound to the MSP of the user
+	//Without one, the MSP ID is kept as organization ID, as organizations registered before MSP IDs were recorded used it as ID
+	orgID, err := s.getOrganizationIDByMSP(ctx, mspID)
+	if err != nil {
+		return err
+	}
+
+	if orgID == "" {
+		orgID = mspID
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	doc, err := s.newDoc(ctx, RequestDoc, clientID)
+	if err != nil {
+		return err
 	}
 
 	r := RequestInner{
-		Doc:         doc,
-		ID:          s.GetRequestID(ctx, id),
-		Description: description,
-		Status:      RequestStatusOpen,
+		Doc:            doc,
+		ID:             s.GetRequestID(ctx, id),
+		Description:    description,
+		Status:         RequestStatusOpen,
+		Sealed:         sealed,
+		OrganizationID: orgID,
+	}
+
+	if sealed {
+		r.BiddingClosesAt = now.Add(time.Duration(biddingWindow) * time.Second)
+		r.BiddingClosesAtUTC = sortableTime(r.BiddingClosesAt)
 	}
 
 	assetBytes, err := json.Marshal(r)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to encode asset %s: %v", r.ID, err)
 	}
 
 	err = ctx.GetStub().PutState(r.ID, assetBytes)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to put asset %s: %v", r.ID, err)
 	}
 
-	return nil
+	return s.putReferenceIndexes(ctx, RequestDoc, id, assetBytes)
 }
 
 //Sets the status of the request to "CLOSED"
@@ -108,7 +193,7 @@ func (s *SmartContract) CloseRequest(ctx contractapi.TransactionContextInterface
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+		return newError(ErrNotFound, "the asset %s does not exist", id)
 	}
 
 	request, err := s.GetRequestInner(ctx, id)
@@ -116,20 +201,195 @@ func (s *SmartContract) CloseRequest(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
+	if err := s.checkOwner(ctx, request.Owner); err != nil {
+		return err
+	}
+
 	if request.Status == RequestStatusClosed {
-		return fmt.Errorf("can't close")
+		return newError(ErrInvalidState, "request %s is already closed", id)
 	}
 
 	request.Status = RequestStatusClosed
 
-	assetBytes, err := json.Marshal(request)
+	return s.putRequestInner(ctx, request)
+}
+
+//Awards the request with the given ID to the offer with the given ID
+//Only the user that created the request, or a user holding the admin attribute, can accept an offer
+//The request is closed and records the chosen offer, which is stored as ACCEPTED while every other pending offer for the request reads as REJECTED
+//Offers for a sealed-bid request can only be accepted after the bidding window closes and once revealed
+//When createAgreement is true an agreement is opened to track the awarded work until delivery
+func (s *SmartContract) AcceptOffer(ctx contractapi.TransactionContextInterface, requestID string, offerID string, createAgreement bool) error {
+	if err := s.HasPermission(ctx, RequestsUpdate); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OffersUpdate); err != nil {
+		return err
+	}
+
+	request, err := s.GetRequestInner(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if request.CreatedBy != clientID {
+		if err := s.HasPermission(ctx, Admin); err != nil {
+			return newError(ErrForbidden, "only the creator of the request can accept offers")
+		}
+	}
+
+	if request.Status == RequestStatusClosed {
+		return newError(ErrInvalidState, "request is closed, can't accept offers")
+	}
+
+	accepted, err := s.GetOfferInner(ctx, offerID)
+	if err != nil {
+		return err
+	}
+
+	if accepted.RequestID != requestID {
+		return newError(ErrNotFound, "offer %s does not exist for request %s", offerID, requestID)
+	}
+
+	if accepted.Status != OfferStatusPending {
+		return newError(ErrInvalidState, "offer %s is %s, can't accept", offerID, accepted.Status)
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isOfferExpired(accepted, now) {
+		return newError(ErrInvalidState, "offer %s has expired, can't accept", offerID)
+	}
+
+	if request.Sealed && isBiddingOpen(request, now) {
+		return newError(ErrInvalidState, "bidding is still open, can't accept offers")
+	}
+
+	if accepted.Sealed {
+		return newError(ErrInvalidState, "offer %s has not been revealed, can't accept", offerID)
+	}
+
+	request.Status = RequestStatusClosed
+	request.AcceptedOfferID = offerID
+	request.UpdatedBy = clientID
+	if err := s.putRequestInner(ctx, request); err != nil {
+		return err
+	}
+
+	//The key endorsement policy of the offer includes the requesting organization, unlike the other offers for the request
+	accepted.Status = OfferStatusAccepted
+	accepted.UpdatedBy = clientID
+	if err := s.putOfferInner(ctx, accepted); err != nil {
+		return err
+	}
+
+	if createAgreement {
+		if err := s.createAgreement(ctx, request, accepted, clientID); err != nil {
+			return err
+		}
+	}
+
+	eventBody, err := NewOfferAcceptedEvent(requestID, offerID, accepted.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(OfferAcceptedEventKey, eventBody)
+}
+
+//Archives the request with the given ID
+//Only the organization that created the request can delete it, an open request is closed first
+func (s *SmartContract) DeleteRequest(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, RequestsDelete); err != nil {
+		return err
+	}
+
+	request, err := s.GetRequestInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, request.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&request.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	request.Status = RequestStatusClosed
+
+	return s.putRequestInner(ctx, request)
+}
+
+//Restores the archived request with the given ID
+//The request stays closed
+func (s *SmartContract) RestoreRequest(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, RequestsDelete); err != nil {
+		return err
+	}
+
+	request, err := s.GetRequestInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, request.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(request.ID, assetBytes)
+	if err := restoreDoc(&request.Doc, clientID); err != nil {
+		return err
+	}
 
-	return nil
+	return s.putRequestInner(ctx, request)
+}
+
+//Stores the given RequestInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetRequestInner
+func (s *SmartContract) putRequestInner(ctx contractapi.TransactionContextInterface, request *RequestInner) error {
+	if err := s.touchDoc(ctx, &request.Doc); err != nil {
+		return err
+	}
+
+	stored := *request
+	stored.ID = s.GetRequestID(ctx, request.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
+	}
+
+	return s.putReferenceIndexes(ctx, RequestDoc, request.ID, assetBytes)
 }
 
 //Returns Request with the given ID
@@ -140,11 +400,11 @@ func (s *SmartContract) GetRequest(ctx contractapi.TransactionContextInterface,
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetRequestID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var r RequestInner
@@ -154,6 +414,10 @@ func (s *SmartContract) GetRequest(ctx contractapi.TransactionContextInterface,
 	}
 
 	r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return s.FromRequestInner(ctx, &r), nil
 }
 
@@ -165,11 +429,11 @@ func (s *SmartContract) GetRequestInner(ctx contractapi.TransactionContextInterf
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetRequestID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var r RequestInner
@@ -179,6 +443,10 @@ func (s *SmartContract) GetRequestInner(ctx contractapi.TransactionContextInterf
 	}
 
 	r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return &r, nil
 }
 
@@ -188,9 +456,14 @@ func (s *SmartContract) GetAllRequests(ctx contractapi.TransactionContextInterfa
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s"}}`, RequestDoc))
+	query, err := activeDocsQuery(RequestDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -207,25 +480,114 @@ func (s *SmartContract) GetAllRequests(ctx contractapi.TransactionContextInterfa
 		}
 
 		r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromRequestInner(ctx, &r))
 	}
 	return assets, nil
 }
 
+//Returns one page of all Request
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllRequestsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*RequestsPage, error) {
+	if err := s.HasPermission(ctx, RequestsRead); err != nil {
+		return nil, err
+	}
+
+	query, err := activeDocsQuery(RequestDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &RequestsPage{Records: []*Request{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var r RequestInner
+		if err := json.Unmarshal(value, &r); err != nil {
+			return err
+		}
+
+		r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromRequestInner(ctx, &r))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
+//Returns all Request created between from (inclusive) and to (exclusive), oldest first
+//Either end of the range can be left open with the zero time
+func (s *SmartContract) GetAllRequestsCreatedBetween(ctx contractapi.TransactionContextInterface, from time.Time, to time.Time) ([]*Request, error) {
+	if err := s.HasPermission(ctx, RequestsRead); err != nil {
+		return nil, err
+	}
+
+	query, err := getCreatedBetweenQuery(RequestDoc, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var assets []*Request
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+		var r RequestInner
+		err = json.Unmarshal(queryResult.Value, &r)
+		if err != nil {
+			return nil, err
+		}
+
+		r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, s.FromRequestInner(ctx, &r))
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return isCreatedBefore(assets[i].CreatedAt, assets[i].ID, assets[j].CreatedAt, assets[j].ID)
+	})
+
+	return assets, nil
+}
+
 //Returns all Request with the given status
 func (s *SmartContract) GetAllRequestsByStatus(ctx contractapi.TransactionContextInterface, statusInput string) ([]*Request, error) {
 	if err := s.HasPermission(ctx, RequestsRead); err != nil {
 		return nil, err
 	}
 
-	status, err := ParseOrderStatus(statusInput)
+	status, err := ParseRequestStatus(statusInput)
 	if err != nil {
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","status":"%s"}}`, RequestDoc, status))
+	query, err := requestsByStatusQuery(status)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -242,8 +604,52 @@ func (s *SmartContract) GetAllRequestsByStatus(ctx contractapi.TransactionContex
 		}
 
 		r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromRequestInner(ctx, &r))
 	}
 
 	return assets, nil
 }
+
+//Returns one page of all Request with the given status
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllRequestsByStatusWithPagination(ctx contractapi.TransactionContextInterface, statusInput string, pageSize int32, bookmark string) (*RequestsPage, error) {
+	if err := s.HasPermission(ctx, RequestsRead); err != nil {
+		return nil, err
+	}
+
+	status, err := ParseRequestStatus(statusInput)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := requestsByStatusQuery(status)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &RequestsPage{Records: []*Request{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var r RequestInner
+		if err := json.Unmarshal(value, &r); err != nil {
+			return err
+		}
+
+		r.ID = strings.TrimPrefix(r.ID, string(RequestDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &r.Doc, r.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromRequestInner(ctx, &r))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
@@ -1,9 +1,5 @@
 package main
 
-import (
-	"fmt"
-)
-
 const (
 	RequestStatusOpen   RequestStatus = "OPEN"
 	RequestStatusClosed RequestStatus = "CLOSED"
@@ -23,5 +19,5 @@ func ParseRequestStatus(status string) (RequestStatus, error) {
 		return RequestStatusClosed, nil
 	}
 
-	return "", fmt.Errorf("invalid request status")
+	return "", newError(ErrInvalidArgument, "invalid request status")
 }
@@ -0,0 +1,601 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	RoleDoc           DocType = "role"
+	RoleAssignmentDoc DocType = "role_assignment"
+)
+
+//Represents data stored in database
+//Contains the doctype
+//A role bundles attributes so they can be granted on the ledger instead of in the certificate of the user
+type RoleInner struct {
+	Doc
+
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+}
+
+type Role struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	Archived    bool        `json:"archived,omitempty"`
+	ArchivedAt  time.Time   `json:"archived_at"`
+}
+
+//Represents data stored in database
+//Contains the doctype
+//Stores the roles granted to a single client identity or MSP
+type RoleAssignmentInner struct {
+	Doc
+
+	ID          string          `json:"id"`
+	SubjectType RoleSubjectType `json:"subject_type"`
+	Subject     string          `json:"subject"`
+	RoleIDs     []string        `json:"role_ids"`
+}
+
+type RoleAssignment struct {
+	SubjectType RoleSubjectType `json:"subject_type"`
+	Subject     string          `json:"subject"`
+	RoleIDs     []string        `json:"role_ids"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+//Parse role from the data on the database
+func FromRoleInner(r *RoleInner) *Role {
+	return &Role{
+		ID:          r.ID,
+		Description: r.Description,
+		Attributes:  r.Attributes,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+		Archived:    r.Archived,
+		ArchivedAt:  r.ArchivedAt,
+	}
+}
+
+//Parse role assignment from the data on the database
+func FromRoleAssignmentInner(r *RoleAssignmentInner) *RoleAssignment {
+	return &RoleAssignment{
+		SubjectType: r.SubjectType,
+		Subject:     r.Subject,
+		RoleIDs:     r.RoleIDs,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func (s *SmartContract) GetRoleID(_ contractapi.TransactionContextInterface, id string) string {
+	return string(RoleDoc) + "_" + id
+}
+
+func (s *SmartContract) GetRoleAssignmentID(_ contractapi.TransactionContextInterface, subjectType RoleSubjectType, subject string) string {
+	return string(RoleAssignmentDoc) + "_" + subjectType.String() + "_" + subject
+}
+
+//Checks if role with the given ID exists
+func (s *SmartContract) RoleExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	assetJSON, err := ctx.GetStub().GetState(s.GetRoleID(ctx, id))
+	if err != nil {
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
+	}
+
+	return assetJSON != nil, nil
+}
+
+//Creates a new role with the given ID
+//User inputs the ID of the role, a description and the list of attributes granted by the role
+//roles.admin and admin can't be granted by a role
+//The ID of an archived role can't be used again, restore the role instead
+func (s *SmartContract) CreateRole(ctx contractapi.TransactionContextInterface, id string, description string, attributes []string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("description", description, maxLength(MaxDescriptionLength)),
+		field("attributes", attributes, required, each(oneOf(attributeNames()...))),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, RolesAdmin); err != nil {
+		return err
+	}
+
+	exists, err := s.RoleExist(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	doc, err := s.newDoc(ctx, RoleDoc, clientID)
+	if err != nil {
+		return err
+	}
+
+	role := RoleInner{
+		Doc:         doc,
+		ID:          id,
+		Description: description,
+		Attributes:  toAttributes(attributes),
+	}
+
+	return s.putRoleInner(ctx, &role)
+}
+
+//Replaces the description and the attributes of the role with the given ID
+//The change applies at once to every client and MSP the role is granted to
+func (s *SmartContract) UpdateRole(ctx contractapi.TransactionContextInterface, id string, description string, attributes []string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("description", description, maxLength(MaxDescriptionLength)),
+		field("attributes", attributes, required, each(oneOf(attributeNames()...))),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, RolesAdmin); err != nil {
+		return err
+	}
+
+	role, err := s.GetRoleInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	role.Description = description
+	role.Attributes = toAttributes(attributes)
+	role.UpdatedBy = clientID
+
+	return s.putRoleInner(ctx, role)
+}
+
+//Archives the role with the given ID
+//Assignments still naming the role no longer grant anything, until they are revoked or the role is restored
+func (s *SmartContract) DeleteRole(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, RolesAdmin); err != nil {
+		return err
+	}
+
+	role, err := s.getRoleInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if role == nil {
+		return newError(ErrNotFound, "asset %s does not exist", id)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&role.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	return s.putRoleInner(ctx, role)
+}
+
+//Restores the archived role with the given ID
+//Assignments still naming the role grant its attributes again
+func (s *SmartContract) RestoreRole(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, RolesAdmin); err != nil {
+		return err
+	}
+
+	role, err := s.getRoleInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if role == nil {
+		return newError(ErrNotFound, "asset %s does not exist", id)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&role.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putRoleInner(ctx, role)
+}
+
+//Grants the role with the given ID to a client identity or to every client of an MSP
+//User inputs the subject type (CLIENT or MSP), the client ID or MSP ID and the ID of the role
+func (s *SmartContract) GrantRole(ctx contractapi.TransactionContextInterface, subjectTypeInput string, subject string, roleID string) error {
+	if err := validate(
+		field("subject", subject, required, maxLength(MaxDescriptionLength)),
+		field("role_id", roleID, isID),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, RolesAdmin); err != nil {
+		return err
+	}
+
+	subjectType, err := ParseRoleSubjectType(subjectTypeInput)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.RoleExist(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return newError(ErrNotFound, "role %s does not exist", roleID)
+	}
+
+	archived, err := isArchived(ctx, s.GetRoleID(ctx, roleID))
+	if err != nil {
+		return err
+	}
+
+	if archived {
+		return newError(ErrInvalidState, "role %s is archived", roleID)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	assignment, err := s.getRoleAssignmentInner(ctx, subjectType, subject)
+	if err != nil {
+		return err
+	}
+
+	if assignment == nil {
+		doc, err := s.newDoc(ctx, RoleAssignmentDoc, clientID)
+		if err != nil {
+			return err
+		}
+
+		assignment = &RoleAssignmentInner{
+			Doc:         doc,
+			SubjectType: subjectType,
+			Subject:     subject,
+		}
+	}
+
+	for _, id := range assignment.RoleIDs {
+		if id == roleID {
+			return newError(ErrConflict, "role %s is already granted to %s", roleID, subject)
+		}
+	}
+
+	assignment.RoleIDs = append(assignment.RoleIDs, roleID)
+	assignment.UpdatedBy = clientID
+
+	return s.putRoleAssignmentInner(ctx, assignment)
+}
+
+//Revokes the role with the given ID from a client identity or MSP
+//User inputs the same arguments as GrantRole
+func (s *SmartContract) RevokeRole(ctx contractapi.TransactionContextInterface, subjectTypeInput string, subject string, roleID string) error {
+	if err := validate(
+		field("subject", subject, required, maxLength(MaxDescriptionLength)),
+		field("role_id", roleID, isID),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, RolesAdmin); err != nil {
+		return err
+	}
+
+	subjectType, err := ParseRoleSubjectType(subjectTypeInput)
+	if err != nil {
+		return err
+	}
+
+	assignment, err := s.getRoleAssignmentInner(ctx, subjectType, subject)
+	if err != nil {
+		return err
+	}
+
+	var roleIDs []string
+	if assignment != nil {
+		for _, id := range assignment.RoleIDs {
+			if id != roleID {
+				roleIDs = append(roleIDs, id)
+			}
+		}
+	}
+
+	if assignment == nil || len(roleIDs) == len(assignment.RoleIDs) {
+		return newError(ErrNotFound, "role %s is not granted to %s", roleID, subject)
+	}
+
+	if len(roleIDs) == 0 {
+		err = ctx.GetStub().DelState(s.GetRoleAssignmentID(ctx, subjectType, subject))
+		if err != nil {
+			return newError(ErrInternal, "failed to delete role assignment of %s: %v", subject, err)
+		}
+
+		return nil
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	assignment.RoleIDs = roleIDs
+	assignment.UpdatedBy = clientID
+
+	return s.putRoleAssignmentInner(ctx, assignment)
+}
+
+//Returns true if a role granted to the current user or to its MSP contains the given attribute
+//Reads the ledger directly, as it is called by HasPermission
+func (s *SmartContract) hasRoleAttribute(ctx contractapi.TransactionContextInterface, att Attribute) (bool, error) {
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	mspID, err := s.GetSubmittingClientOrganization(ctx)
+	if err != nil {
+		return false, newError(ErrInternal, "failed to read MSP ID: %v", err)
+	}
+
+	//Checked in a fixed order so every endorsing peer reads the same keys
+	subjects := []struct {
+		subjectType RoleSubjectType
+		subject     string
+	}{
+		{RoleSubjectClient, clientID},
+		{RoleSubjectMSP, mspID},
+	}
+
+	for _, sub := range subjects {
+		assignment, err := s.getRoleAssignmentInner(ctx, sub.subjectType, sub.subject)
+		if err != nil {
+			return false, err
+		}
+
+		if assignment == nil {
+			continue
+		}
+
+		for _, roleID := range assignment.RoleIDs {
+			role, err := s.getRoleInner(ctx, roleID)
+			if err != nil {
+				return false, err
+			}
+
+			//Archived roles no longer grant anything
+			if role == nil || role.Archived {
+				continue
+			}
+
+			for _, a := range role.Attributes {
+				if a == att {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func toAttributes(values []string) []Attribute {
+	atts := make([]Attribute, 0, len(values))
+	for _, v := range values {
+		atts = append(atts, Attribute(v))
+	}
+
+	return atts
+}
+
+//Stores the given RoleInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetRoleInner
+func (s *SmartContract) putRoleInner(ctx contractapi.TransactionContextInterface, role *RoleInner) error {
+	if err := s.touchDoc(ctx, &role.Doc); err != nil {
+		return err
+	}
+
+	stored := *role
+	stored.ID = s.GetRoleID(ctx, role.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
+	}
+
+	return nil
+}
+
+//Stores the given RoleAssignmentInner under the key of its subject in the world state
+func (s *SmartContract) putRoleAssignmentInner(ctx contractapi.TransactionContextInterface, assignment *RoleAssignmentInner) error {
+	if err := s.touchDoc(ctx, &assignment.Doc); err != nil {
+		return err
+	}
+	assignment.ID = s.GetRoleAssignmentID(ctx, assignment.SubjectType, assignment.Subject)
+
+	assetBytes, err := json.Marshal(assignment)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", assignment.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(assignment.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", assignment.ID, err)
+	}
+
+	return nil
+}
+
+//Returns the RoleInner with the given ID, or nil if it does not exist
+//Does not check permissions
+func (s *SmartContract) getRoleInner(ctx contractapi.TransactionContextInterface, id string) (*RoleInner, error) {
+	assetBytes, err := ctx.GetStub().GetState(s.GetRoleID(ctx, id))
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
+	}
+
+	if assetBytes == nil {
+		return nil, nil
+	}
+
+	var role RoleInner
+	err = json.Unmarshal(assetBytes, &role)
+	if err != nil {
+		return nil, err
+	}
+
+	role.ID = strings.TrimPrefix(role.ID, string(RoleDoc)+"_")
+	return &role, nil
+}
+
+//Returns the RoleAssignmentInner of the given subject, or nil if no role is granted to it
+//Does not check permissions
+func (s *SmartContract) getRoleAssignmentInner(ctx contractapi.TransactionContextInterface, subjectType RoleSubjectType, subject string) (*RoleAssignmentInner, error) {
+	assetBytes, err := ctx.GetStub().GetState(s.GetRoleAssignmentID(ctx, subjectType, subject))
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get role assignment of %s: %v", subject, err)
+	}
+
+	if assetBytes == nil {
+		return nil, nil
+	}
+
+	var assignment RoleAssignmentInner
+	err = json.Unmarshal(assetBytes, &assignment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &assignment, nil
+}
+
+//Returns RoleInner with the given ID
+func (s *SmartContract) GetRoleInner(ctx contractapi.TransactionContextInterface, id string) (*RoleInner, error) {
+	if err := s.HasPermission(ctx, RolesRead); err != nil {
+		return nil, err
+	}
+
+	role, err := s.getRoleInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
+	}
+
+	return role, nil
+}
+
+//Returns Role with the given ID
+func (s *SmartContract) GetRole(ctx contractapi.TransactionContextInterface, id string) (*Role, error) {
+	role, err := s.GetRoleInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromRoleInner(role), nil
+}
+
+//Returns all Role in the system
+func (s *SmartContract) GetAllRoles(ctx contractapi.TransactionContextInterface) ([]*Role, error) {
+	if err := s.HasPermission(ctx, RolesRead); err != nil {
+		return nil, err
+	}
+
+	query, err := activeDocsQuery(RoleDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets: %v", err)
+	}
+
+	defer results.Close()
+
+	var assets []*Role
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+		var role RoleInner
+		err = json.Unmarshal(queryResult.Value, &role)
+		if err != nil {
+			return nil, err
+		}
+
+		role.ID = strings.TrimPrefix(role.ID, string(RoleDoc)+"_")
+		assets = append(assets, FromRoleInner(&role))
+	}
+
+	return assets, nil
+}
+
+//Returns the roles granted to the given client identity or MSP
+//User inputs the subject type (CLIENT or MSP) and the client ID or MSP ID
+func (s *SmartContract) GetRoleAssignment(ctx contractapi.TransactionContextInterface, subjectTypeInput string, subject string) (*RoleAssignment, error) {
+	if err := s.HasPermission(ctx, RolesRead); err != nil {
+		return nil, err
+	}
+
+	subjectType, err := ParseRoleSubjectType(subjectTypeInput)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.getRoleAssignmentInner(ctx, subjectType, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if assignment == nil {
+		return nil, newError(ErrNotFound, "no role is granted to %s", subject)
+	}
+
+	return FromRoleAssignmentInner(assignment), nil
+}
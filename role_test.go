@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+//An archived role stops granting its attributes, keeps its ID and grants them again once restored
+func TestDeleteRole(t *testing.T) {
+	owner := newTestIdentity(t, "Org1MSP", "user1", testAttributes(false))
+	counterparty := newTestIdentity(t, "Org2MSP", "user2", testAttributes(false))
+	other := newTestIdentity(t, "Org3MSP", "user3", testAttributes(false))
+	admin := newTestIdentity(t, "Org4MSP", "admin", testAttributes(true))
+	reader := newTestIdentity(t, "Org5MSP", "user5", map[string]string{})
+	roleAdmin := newTestIdentity(t, "Org4MSP", "roles", map[string]string{RolesAdmin.String(): "true"})
+
+	f := newOwnershipFixture(t, owner, counterparty, other, admin)
+	s := f.contract
+
+	f.must(s.CreateRole(f.as(roleAdmin), "reader", "Reads orders", []string{OrdersRead.String()}))
+	f.must(s.GrantRole(f.as(roleAdmin), "MSP", "Org5MSP", "reader"))
+	f.must(s.HasPermission(f.as(reader), OrdersRead))
+
+	f.must(s.DeleteRole(f.as(roleAdmin), "reader"))
+	if err := s.HasPermission(f.as(reader), OrdersRead); testErrorCode(err) != ErrForbidden {
+		t.Fatalf("expected %s, got %v", ErrForbidden, err)
+	}
+
+	if err := s.CreateRole(f.as(roleAdmin), "reader", "Reads orders", []string{OrdersRead.String()}); testErrorCode(err) != ErrAlreadyExists {
+		t.Fatalf("expected %s, got %v", ErrAlreadyExists, err)
+	}
+
+	if err := s.GrantRole(f.as(roleAdmin), "CLIENT", "user1", "reader"); testErrorCode(err) != ErrInvalidState {
+		t.Fatalf("expected %s, got %v", ErrInvalidState, err)
+	}
+
+	f.must(s.RestoreRole(f.as(roleAdmin), "reader"))
+	f.must(s.HasPermission(f.as(reader), OrdersRead))
+}
@@ -0,0 +1,26 @@
+package main
+
+const (
+	RoleSubjectClient RoleSubjectType = "CLIENT"
+	RoleSubjectMSP    RoleSubjectType = "MSP"
+)
+
+//Who a role is granted to
+//CLIENT grants the role to a single client identity, as returned by GetSubmittingClientIdentity
+//MSP grants the role to every client of the organization with the given MSP ID
+type RoleSubjectType string
+
+func (r RoleSubjectType) String() string {
+	return string(r)
+}
+
+func ParseRoleSubjectType(subjectType string) (RoleSubjectType, error) {
+	switch subjectType {
+	case "CLIENT":
+		return RoleSubjectClient, nil
+	case "MSP":
+		return RoleSubjectMSP, nil
+	}
+
+	return "", newError(ErrInvalidArgument, "invalid role subject type")
+}
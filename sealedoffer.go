@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Returns the commitment for an offer with the given ID, value and salt
+//The commitment is the hex encoded SHA-256 of "id:amount:exponent:currency:salt"
+//Clients must compute it off-chain so the value never appears in the transaction arguments before the reveal
+func NewOfferCommitment(id string, value Price, salt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d:%s:%s", id, value.Amount, value.Exponent, value.Currency, salt)))
+	return hex.EncodeToString(sum[:])
+}
+
+//Checks whether the bidding window of the request is still open at the given time
+func isBiddingOpen(request *RequestInner, now time.Time) bool {
+	return now.Before(request.BiddingClosesAt)
+}
+
+//Creates a new sealed offer for the sealed-bid request with the given ID
+//Only the commitment is stored, the value is disclosed later with RevealOffer
+//User inputs the ID of the offer, the commitment, the ID of the organization, the ID of the request and for how many seconds the offer is valid (0 for no deadline)
+func (s *SmartContract) MakeSealedOffer(ctx contractapi.TransactionContextInterface, id string, commitment string, organizationID string, requestID string, validity uint32) error {
+	if err := validate(
+		field("id", id, isID),
+		field("commitment", commitment, required),
+		field("organization_id", organizationID, isID),
+		field("request_id", requestID, isID),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OffersCreate); err != nil {
+		return err
+	}
+
+	mspID, err := s.getOrganizationMSPID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, mspID); err != nil {
+		return err
+	}
+
+	if err := s.checkOrganizationActive(ctx, organizationID); err != nil {
+		return err
+	}
+
+	commitment = strings.ToLower(commitment)
+	if b, err := hex.DecodeString(commitment); err != nil || len(b) != sha256.Size {
+		return newError(ErrInvalidArgument, "invalid commitment")
+	}
+
+	exists, err := s.OfferExist(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
+	}
+
+	hasOrg, err := s.OrganizationExist(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if !hasOrg {
+		return newError(ErrNotFound, "organization %s does not exist", organizationID)
+	}
+
+	archived, err := isArchived(ctx, s.GetOrganizationID(ctx, organizationID))
+	if err != nil {
+		return err
+	}
+	if archived {
+		return newError(ErrInvalidState, "organization %s is archived", organizationID)
+	}
+
+	request, err := s.GetRequestInner(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	if request.Status == RequestStatusClosed {
+		return newError(ErrInvalidState, "request is closed, can't offer")
+	}
+
+	if !request.Sealed {
+		return newError(ErrInvalidState, "request is not sealed, use MakeOffer")
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !isBiddingOpen(request, now) {
+		return newError(ErrInvalidState, "bidding is closed, can't offer")
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	doc, err := s.newDoc(ctx, OfferDoc, clientID)
+	if err != nil {
+		return err
+	}
+
+	doc.Owner = mspID
+
+	offer := OfferInner{
+		Doc:            doc,
+		ID:             s.GetOfferID(ctx, id),
+		Status:         OfferStatusPending,
+		OrganizationID: organizationID,
+		RequestID:      requestID,
+		ValidUntil:     getOfferValidUntil(now, validity),
+		Sealed:         true,
+		Commitment:     commitment,
+	}
+	offer.ValidUntilUTC = sortableTime(offer.ValidUntil)
+
+	assetBytes, err := json.Marshal(offer)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", offer.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(offer.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", offer.ID, err)
+	}
+
+	if err := s.putReferenceIndexes(ctx, OfferDoc, id, assetBytes); err != nil {
+		return err
+	}
+
+	//Same policy as MakeOffer, the reveal needs the requesting organization as well
+	return s.setKeyEndorsement(ctx, offer.ID, mspID, request.Owner)
+}
+
+//Discloses the value of the sealed offer with the given ID
+//Only the organization that made the offer can reveal it, and only after the bidding window of the request closes
+//The value and salt must match the commitment stored with the offer
+func (s *SmartContract) RevealOffer(ctx contractapi.TransactionContextInterface, id string, value uint64, currency string, exponent uint32, salt string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("value", value, nonZero),
+		field("currency", currency, isCurrency),
+		field("exponent", exponent, maxValue(MaxPriceExponent)),
+		field("salt", salt, required),
+	); err != nil {
+		return err
+	}
+
+	if err := s.HasPermission(ctx, OffersUpdate); err != nil {
+		return err
+	}
+
+	offer, err := s.getOwnPendingOfferInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !offer.Sealed {
+		return newError(ErrInvalidState, "offer %s is not sealed", id)
+	}
+
+	request, err := s.GetRequestInner(ctx, offer.RequestID)
+	if err != nil {
+		return err
+	}
+
+	if request.Status == RequestStatusClosed {
+		return newError(ErrInvalidState, "request is closed, can't reveal offer")
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isBiddingOpen(request, now) {
+		return newError(ErrInvalidState, "bidding is still open, can't reveal offer")
+	}
+
+	price := Price{
+		Amount:   value,
+		Currency: currency,
+		Exponent: exponent,
+	}
+
+	if NewOfferCommitment(id, price, salt) != offer.Commitment {
+		return newError(ErrInvalidArgument, "revealed value does not match the commitment")
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	offer.Value = price
+	offer.Sealed = false
+	offer.UpdatedBy = clientID
+
+	return s.putOfferInner(ctx, offer)
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//Type of the values stored in a searchable field
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldNumber
+	fieldTime
+	fieldBool
+)
+
+//Fields every document type can be searched on
+var docSearchFields = map[string]fieldKind{
+	"owner":      fieldString,
+	"created_by": fieldString,
+	"updated_by": fieldString,
+	"created_at": fieldTime,
+	"updated_at": fieldTime,
+}
+
+//Fields each document type can be searched and sorted on
+//Prices are compared by their stored amount, so filters on an amount should also fix the exponent and currency
+//The status of an offer is left out, accepted, rejected and expired offers keep the pending status they are stored with, see resolveOfferStatus
+var searchFields = map[DocType]map[string]fieldKind{
+	UnitDoc: {
+		"name":      fieldString,
+		"dimension": fieldString,
+		"exponent":  fieldNumber,
+	},
+	ProductDoc: {
+		"name": fieldString,
+	},
+	OrganizationDoc: {
+		"name":    fieldString,
+		"address": fieldString,
+		"msp_id":  fieldString,
+		"status":  fieldString,
+	},
+	OrderDoc: {
+		"amount":          fieldNumber,
+		"filled":          fieldNumber,
+		"remaining":       fieldNumber,
+		"price.amount":    fieldNumber,
+		"price.exponent":  fieldNumber,
+		"price.currency":  fieldString,
+		"type":            fieldString,
+		"status":          fieldString,
+		"organization_id": fieldString,
+		"product_id":      fieldString,
+		"unit_id":         fieldString,
+		"private_price":   fieldBool,
+	},
+	TransactionDoc: {
+		"amount":           fieldNumber,
+		"status":           fieldString,
+		"organization_id":  fieldString,
+		"order_id":         fieldString,
+		"matched_order_id": fieldString,
+	},
+	RequestDoc: {
+		"status":            fieldString,
+		"organization_id":   fieldString,
+		"sealed":            fieldBool,
+		"bidding_closes_at": fieldTime,
+		"accepted_offer_id": fieldString,
+	},
+	OfferDoc: {
+		"value.amount":    fieldNumber,
+		"value.exponent":  fieldNumber,
+		"value.currency":  fieldString,
+		"organization_id": fieldString,
+		"request_id":      fieldString,
+		"valid_until":     fieldTime,
+		"sealed":          fieldBool,
+		"private_value":   fieldBool,
+	},
+	AgreementDoc: {
+		"value.amount":              fieldNumber,
+		"value.exponent":            fieldNumber,
+		"value.currency":            fieldString,
+		"status":                    fieldString,
+		"organization_id":           fieldString,
+		"requester_organization_id": fieldString,
+		"request_id":                fieldString,
+		"offer_id":                  fieldString,
+	},
+}
+
+//Operators allowed in a filter, range operators only apply to numbers and times
+var searchOperators = map[string]bool{
+	"$eq":  false,
+	"$ne":  false,
+	"$in":  false,
+	"$gt":  true,
+	"$gte": true,
+	"$lt":  true,
+	"$lte": true,
+}
+
+//Page of search results
+//Only the list matching the searched document type is set
+type SearchResult struct {
+	PageInfo
+	Units         []*Unit         `json:"units,omitempty"`
+	Products      []*Product      `json:"products,omitempty"`
+	Organizations []*Organization `json:"organizations,omitempty"`
+	Orders        []*Order        `json:"orders,omitempty"`
+	Transactions  []*Transaction  `json:"transactions,omitempty"`
+	Requests      []*Request      `json:"requests,omitempty"`
+	Offers        []*Offer        `json:"offers,omitempty"`
+	Agreements    []*Agreement    `json:"agreements,omitempty"`
+}
+
+//Returns the kind of the field if documents of the given type can be searched on it
+func getSearchField(docType DocType, field string) (fieldKind, bool) {
+	if kind, ok := docSearchFields[field]; ok {
+		return kind, true
+	}
+
+	kind, ok := searchFields[docType][field]
+	return kind, ok
+}
+
+//Returns the name of the stored field filters and sorts on the given field apply to
+//Times are searched by their fixed width form, stored next to them with the "_utc" suffix
+func getStoredField(field string, kind fieldKind) string {
+	if kind == fieldTime {
+		return field + "_utc"
+	}
+
+	return field
+}
+
+//Checks a single filter value against the kind of the field and returns it as it must be encoded in the selector
+//Times are given in RFC 3339 and encoded in SortableTimeLayout
+func parseSearchValue(field string, kind fieldKind, value interface{}) (interface{}, error) {
+	switch kind {
+	case fieldString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case fieldNumber:
+		if v, ok := value.(json.Number); ok {
+			n, err := strconv.ParseUint(v.String(), 10, 64)
+			if err == nil {
+				return n, nil
+			}
+		}
+	case fieldTime:
+		if v, ok := value.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err == nil {
+				return sortableTime(t), nil
+			}
+		}
+	case fieldBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	}
+
+	return nil, newError(ErrInvalidArgument, "invalid value for %s", field)
+}
+
+//Builds the condition on a field from its filter, either a plain value or an object of operators
+func parseSearchCondition(field string, kind fieldKind, filter interface{}) (interface{}, error) {
+	operators, ok := filter.(map[string]interface{})
+	if !ok {
+		return parseSearchValue(field, kind, filter)
+	}
+
+	condition := make(map[string]interface{}, len(operators))
+	for op, value := range operators {
+		isRange, ok := searchOperators[op]
+		if !ok {
+			return nil, newError(ErrInvalidArgument, "invalid operator %s on %s", op, field)
+		}
+
+		if isRange && kind != fieldNumber && kind != fieldTime {
+			return nil, newError(ErrInvalidArgument, "operator %s can't be used on %s", op, field)
+		}
+
+		if op != "$in" {
+			v, err := parseSearchValue(field, kind, value)
+			if err != nil {
+				return nil, err
+			}
+
+			condition[op] = v
+			continue
+		}
+
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, newError(ErrInvalidArgument, "operator $in on %s expects a list", field)
+		}
+
+		list := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			v, err := parseSearchValue(field, kind, value)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, v)
+		}
+
+		condition[op] = list
+	}
+
+	return condition, nil
+}
+
+//Builds the selector for the given document type from the filter
+//The filter is a JSON object mapping fields to a value or to an object of operators, e.g. {"status":"OPEN","amount":{"$gte":10}}
+//Archived documents are left out unless the filter sets "archived" to true
+func buildSearchSelector(docType DocType, filterJSON string) (map[string]interface{}, error) {
+	selector := activeSelector(docType)
+	if strings.TrimSpace(filterJSON) == "" {
+		return selector, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(filterJSON)))
+	decoder.UseNumber()
+
+	var filter map[string]interface{}
+	if err := decoder.Decode(&filter); err != nil {
+		return nil, newError(ErrInvalidArgument, "invalid filter: %v", err)
+	}
+
+	for field, value := range filter {
+		if field == "archived" {
+			archived, ok := value.(bool)
+			if !ok {
+				return nil, newError(ErrInvalidArgument, "invalid value for archived")
+			}
+
+			if archived {
+				selector["archived"] = true
+			}
+			continue
+		}
+
+		kind, ok := getSearchField(docType, field)
+		if !ok {
+			return nil, newError(ErrInvalidArgument, "%s can't be searched by %s", docType, field)
+		}
+
+		condition, err := parseSearchCondition(field, kind, value)
+		if err != nil {
+			return nil, err
+		}
+
+		selector[getStoredField(field, kind)] = condition
+	}
+
+	return selector, nil
+}
+
+//Builds the sort of the query from a comma separated list of fields, each prefixed with "-" for descending order
+//Only number and time fields can be sorted, each has a CouchDB index on doc_type and the field
+//CouchDB needs every field sorted in the same direction
+func buildSearchSort(docType DocType, sortInput string) ([]map[string]string, error) {
+	if strings.TrimSpace(sortInput) == "" {
+		return nil, nil
+	}
+
+	var sort []map[string]string
+	direction := ""
+	for _, field := range strings.Split(sortInput, ",") {
+		field = strings.TrimSpace(field)
+
+		dir := "asc"
+		if strings.HasPrefix(field, "-") {
+			dir = "desc"
+			field = field[1:]
+		}
+
+		kind, ok := getSearchField(docType, field)
+		if !ok || (kind != fieldNumber && kind != fieldTime) {
+			return nil, newError(ErrInvalidArgument, "%s can't be sorted by %s", docType, field)
+		}
+
+		if direction != "" && dir != direction {
+			return nil, newError(ErrInvalidArgument, "every sort field must use the same direction")
+		}
+		direction = dir
+
+		sort = append(sort, map[string]string{getStoredField(field, kind): dir})
+	}
+
+	return sort, nil
+}
+
+//Searches the documents of the given type matching the filter
+//User inputs the document type, the filter as a JSON object (empty for every document), the sort fields (empty for no order), the page size and the bookmark of the page (empty for the first page)
+//Filters can combine any searchable field of the document type, e.g. {"organization_id":"org1","status":{"$in":["OPEN","PARTIALLY_FILLED"]},"price.amount":{"$lte":500},"price.exponent":2}
+func (s *SmartContract) Search(ctx contractapi.TransactionContextInterface, docTypeInput string, filterJSON string, sort string, pageSize int32, bookmark string) (*SearchResult, error) {
+	docType := DocType(docTypeInput)
+	att, ok := docReadAttributes[docType]
+	if !ok {
+		return nil, newError(ErrInvalidArgument, "invalid doc type")
+	}
+
+	if err := s.HasPermission(ctx, att); err != nil {
+		return nil, err
+	}
+
+	selector, err := buildSearchSelector(docType, filterJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	sortFields, err := buildSearchSort(docType, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := buildQuery(selector, sortFields)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		return s.appendSearchResult(ctx, result, docType, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.PageInfo = info
+	return result, nil
+}
+
+//Parses the stored document and adds it to the list of its type in the result
+func (s *SmartContract) appendSearchResult(ctx contractapi.TransactionContextInterface, result *SearchResult, docType DocType, value []byte) error {
+	prefix := string(docType) + "_"
+
+	switch docType {
+	case UnitDoc:
+		var unit UnitInner
+		if err := json.Unmarshal(value, &unit); err != nil {
+			return err
+		}
+		unit.ID = strings.TrimPrefix(unit.ID, prefix)
+		result.Units = append(result.Units, FromUnitInner(&unit))
+	case ProductDoc:
+		var product ProductInner
+		if err := json.Unmarshal(value, &product); err != nil {
+			return err
+		}
+		product.ID = strings.TrimPrefix(product.ID, prefix)
+		result.Products = append(result.Products, s.FromProductInner(ctx, &product))
+	case OrganizationDoc:
+		var organization OrganizationInner
+		if err := json.Unmarshal(value, &organization); err != nil {
+			return err
+		}
+		organization.ID = strings.TrimPrefix(organization.ID, prefix)
+		result.Organizations = append(result.Organizations, s.FromOrganizationInner(ctx, &organization))
+	case OrderDoc:
+		var order OrderInner
+		if err := json.Unmarshal(value, &order); err != nil {
+			return err
+		}
+		order.ID = strings.TrimPrefix(order.ID, prefix)
+		if err := s.fillLegacyOwner(ctx, &order.Doc, order.OrganizationID); err != nil {
+			return err
+		}
+
+		result.Orders = append(result.Orders, s.FromOrderInner(ctx, &order))
+	case TransactionDoc:
+		var transaction TransactionInner
+		if err := json.Unmarshal(value, &transaction); err != nil {
+			return err
+		}
+		transaction.ID = strings.TrimPrefix(transaction.ID, prefix)
+		if err := s.fillLegacyOwner(ctx, &transaction.Doc, transaction.OrganizationID); err != nil {
+			return err
+		}
+
+		result.Transactions = append(result.Transactions, s.FromTransactionInner(ctx, &transaction))
+	case RequestDoc:
+		var request RequestInner
+		if err := json.Unmarshal(value, &request); err != nil {
+			return err
+		}
+		request.ID = strings.TrimPrefix(request.ID, prefix)
+		if err := s.fillLegacyOwner(ctx, &request.Doc, request.OrganizationID); err != nil {
+			return err
+		}
+
+		result.Requests = append(result.Requests, s.FromRequestInner(ctx, &request))
+	case OfferDoc:
+		var offer OfferInner
+		if err := json.Unmarshal(value, &offer); err != nil {
+			return err
+		}
+		offer.ID = strings.TrimPrefix(offer.ID, prefix)
+		if err := s.fillLegacyOwner(ctx, &offer.Doc, offer.OrganizationID); err != nil {
+			return err
+		}
+
+		if err := s.resolveOfferStatus(ctx, &offer); err != nil {
+			return err
+		}
+
+		result.Offers = append(result.Offers, s.FromOfferInner(ctx, &offer))
+	case AgreementDoc:
+		var agreement AgreementInner
+		if err := json.Unmarshal(value, &agreement); err != nil {
+			return err
+		}
+		agreement.ID = strings.TrimPrefix(agreement.ID, prefix)
+		if err := s.fillLegacyOwner(ctx, &agreement.Doc, agreement.RequesterOrganizationID); err != nil {
+			return err
+		}
+
+		result.Agreements = append(result.Agreements, s.FromAgreementInner(ctx, &agreement))
+	}
+
+	return nil
+}
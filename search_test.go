@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+//Filters only reach the selector through known fields and operators, anything else a caller could inject is refused
+func TestBuildSearchSelector(t *testing.T) {
+	cases := []struct {
+		name    string
+		docType DocType
+		filter  string
+		want    string
+		code    ErrorCode
+	}{
+		{
+			name:    "empty filter",
+			docType: OrderDoc,
+			want:    `{"archived":{"$exists":false},"doc_type":"order"}`,
+		},
+		{
+			name:    "values and operators",
+			docType: OrderDoc,
+			filter:  `{"status":{"$in":["OPEN","PARTIALLY_FILLED"]},"price.amount":{"$lte":500},"owner":"Org1MSP"}`,
+			want:    `{"archived":{"$exists":false},"doc_type":"order","owner":"Org1MSP","price.amount":{"$lte":500},"status":{"$in":["OPEN","PARTIALLY_FILLED"]}}`,
+		},
+		{
+			name:    "times are searched by their sortable form",
+			docType: RequestDoc,
+			filter:  `{"bidding_closes_at":{"$gte":"2024-01-02T03:04:05+01:00"}}`,
+			want:    `{"archived":{"$exists":false},"bidding_closes_at_utc":{"$gte":"2024-01-02T02:04:05.000000000Z"},"doc_type":"request"}`,
+		},
+		{
+			name:    "archived documents",
+			docType: UnitDoc,
+			filter:  `{"archived":true}`,
+			want:    `{"archived":true,"doc_type":"unit"}`,
+		},
+		{name: "doc type can't be overridden", docType: OrderDoc, filter: `{"doc_type":"organization"}`, code: ErrInvalidArgument},
+		{name: "key can't be searched", docType: OrderDoc, filter: `{"_id":{"$gt":null}}`, code: ErrInvalidArgument},
+		{name: "field of another doc type", docType: UnitDoc, filter: `{"status":"OPEN"}`, code: ErrInvalidArgument},
+		{name: "stored offer status", docType: OfferDoc, filter: `{"status":"PENDING"}`, code: ErrInvalidArgument},
+		{name: "combination operator", docType: OrderDoc, filter: `{"$or":[{"doc_type":"organization"}]}`, code: ErrInvalidArgument},
+		{name: "nested object instead of a dotted field", docType: OrderDoc, filter: `{"price":{"amount":{"$gt":0}}}`, code: ErrInvalidArgument},
+		{name: "regex operator", docType: OrderDoc, filter: `{"status":{"$regex":".*"}}`, code: ErrInvalidArgument},
+		{name: "where operator", docType: OrderDoc, filter: `{"status":{"$where":"true"}}`, code: ErrInvalidArgument},
+		{name: "nested operator", docType: OrderDoc, filter: `{"status":{"$ne":{"$exists":true}}}`, code: ErrInvalidArgument},
+		{name: "operator inside a list", docType: OrderDoc, filter: `{"status":{"$in":[{"$gt":""}]}}`, code: ErrInvalidArgument},
+		{name: "range on a string", docType: OrderDoc, filter: `{"status":{"$gt":"A"}}`, code: ErrInvalidArgument},
+		{name: "in without a list", docType: OrderDoc, filter: `{"status":{"$in":"OPEN"}}`, code: ErrInvalidArgument},
+		{name: "string for a number", docType: OrderDoc, filter: `{"amount":"10"}`, code: ErrInvalidArgument},
+		{name: "negative number", docType: OrderDoc, filter: `{"amount":-1}`, code: ErrInvalidArgument},
+		{name: "invalid time", docType: RequestDoc, filter: `{"bidding_closes_at":"tomorrow"}`, code: ErrInvalidArgument},
+		{name: "invalid archived", docType: UnitDoc, filter: `{"archived":{"$exists":true}}`, code: ErrInvalidArgument},
+		{name: "not an object", docType: OrderDoc, filter: `["status"]`, code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			selector, err := buildSearchSelector(c.docType, c.filter)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if c.code != "" {
+				return
+			}
+
+			got, err := json.Marshal(selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != c.want {
+				t.Fatalf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+//Only number and time fields can be sorted, all in the same direction
+func TestBuildSearchSort(t *testing.T) {
+	cases := []struct {
+		name    string
+		docType DocType
+		sort    string
+		want    string
+		code    ErrorCode
+	}{
+		{name: "no sort", docType: OrderDoc, want: `null`},
+		{name: "ascending", docType: OrderDoc, sort: "amount, created_at", want: `[{"amount":"asc"},{"created_at_utc":"asc"}]`},
+		{name: "descending", docType: OrderDoc, sort: "-price.amount,-updated_at", want: `[{"price.amount":"desc"},{"updated_at_utc":"desc"}]`},
+		{name: "mixed directions", docType: OrderDoc, sort: "amount,-created_at", code: ErrInvalidArgument},
+		{name: "string field", docType: OrderDoc, sort: "status", code: ErrInvalidArgument},
+		{name: "unknown field", docType: OrderDoc, sort: "_id", code: ErrInvalidArgument},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			sort, err := buildSearchSort(c.docType, c.sort)
+			if code := testErrorCode(err); code != c.code {
+				t.Fatalf("expected %q, got %v", c.code, err)
+			}
+
+			if c.code != "" {
+				return
+			}
+
+			got, err := json.Marshal(sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != c.want {
+				t.Fatalf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
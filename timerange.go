@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+)
+
+//Builds the query for the documents of the given type created between from (inclusive) and to (exclusive)
+//A zero time leaves that end of the range open
+//Archived documents are left out like in the other list queries
+func getCreatedBetweenQuery(docType DocType, from time.Time, to time.Time) (string, error) {
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return "", newError(ErrInvalidArgument, "invalid time range, %s is not before %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	createdAt := map[string]interface{}{}
+	if !from.IsZero() {
+		createdAt["$gte"] = sortableTime(from)
+	}
+	if !to.IsZero() {
+		createdAt["$lt"] = sortableTime(to)
+	}
+
+	selector := activeSelector(docType)
+	if len(createdAt) > 0 {
+		selector["created_at_utc"] = createdAt
+	}
+
+	return buildQuery(selector, nil)
+}
+
+//Orders the documents from the oldest to the newest, breaking ties by ID
+func isCreatedBefore(a time.Time, aID string, b time.Time, bID string) bool {
+	if !a.Equal(b) {
+		return a.Before(b)
+	}
+
+	return aID < bID
+}
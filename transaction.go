@@ -2,8 +2,9 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -25,6 +26,7 @@ type TransactionInner struct {
 	Status         TransactionStatus `json:"status"`
 	OrganizationID string            `json:"organization_id"`
 	OrderID        string            `json:"order_id"`
+	MatchedOrderID string            `json:"matched_order_id,omitempty"`
 }
 
 type Transaction struct {
@@ -34,6 +36,12 @@ type Transaction struct {
 	Status         TransactionStatus `json:"status"`
 	OrganizationID string            `json:"organization_id"`
 	OrderID        string            `json:"order_id"`
+	MatchedOrderID string            `json:"matched_order_id,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	Archived       bool              `json:"archived,omitempty"`
+	ArchivedAt     time.Time         `json:"archived_at"`
+	Owner          string            `json:"owner"`
 }
 
 type NewTransactionEvent struct {
@@ -56,6 +64,12 @@ func (s *SmartContract) FromTransactionInner(_ contractapi.TransactionContextInt
 		Status:         p.Status,
 		OrganizationID: p.OrganizationID,
 		OrderID:        p.OrderID,
+		MatchedOrderID: p.MatchedOrderID,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+		Archived:       p.Archived,
+		ArchivedAt:     p.ArchivedAt,
+		Owner:          p.Owner,
 	}
 }
 
@@ -63,20 +77,20 @@ func (s *SmartContract) GetTransactionID(_ contractapi.TransactionContextInterfa
 	return string(TransactionDoc) + "_" + id
 }
 
-//Retrieve value of "amount" between various transactions
-//Used to ensure that the order to which a new transaction is being prepared still has enough product available to be bought
-func getTransactionsAmount(transactions []*TransactionInner) uint32 {
-	out := uint32(0)
-
-	for _, t := range transactions {
-		if t.Status == TransactionStatusCanceled {
-			continue
-		}
+//Checks whether a transaction with the given status takes its amount from the order
+//Only canceled transactions give their amount back to the order
+//Not delivered transactions keep it reserved, as they can still be delivered
+func isTransactionFilling(status TransactionStatus) bool {
+	return status != TransactionStatusCanceled
+}
 
-		out += t.Amount
+//Returns the IDs of the orders filled by the transaction
+func getTransactionOrderIDs(t *TransactionInner) []string {
+	if t.MatchedOrderID == "" {
+		return []string{t.OrderID}
 	}
 
-	return out
+	return []string{t.OrderID, t.MatchedOrderID}
 }
 
 func NewNewTransactionEvent(id string) ([]byte, error) {
@@ -91,7 +105,7 @@ func NewTransactionStatusChangedEvent(id string, oldStatus TransactionStatus, ne
 func (s *SmartContract) TransactionExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetTransactionID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state:%v", err)
+		return false, newError(ErrInternal, "failed to read from world state:%v", err)
 	}
 
 	return assetJSON != nil, nil
@@ -99,40 +113,103 @@ func (s *SmartContract) TransactionExist(ctx contractapi.TransactionContextInter
 
 //Creates a new transaction for the order with the given ID
 //User inputs the ID of the transaction, the total amount of product being bought/sold, the organization doing the transaction and the order to which the transaction is related
+//The amount is in the unit of the order and is taken from the remaining quantity of the order, use MakeTransactionInUnit to give it in another unit
+//The order is updated as well, so a peer of the organization of the order must endorse the transaction besides the one making it
 func (s *SmartContract) MakeTransaction(ctx contractapi.TransactionContextInterface, id string, amount uint32, organizationID string, orderID string) error {
+	return s.makeTransaction(ctx, id, amount, organizationID, orderID, "")
+}
+
+//Creates a new transaction for the order with the given ID, with the amount given in the unit with the given ID
+//The unit can be any unit compatible with the unit of the order, the amount is then converted to the unit of the order
+func (s *SmartContract) MakeTransactionInUnit(ctx contractapi.TransactionContextInterface, id string, amount uint32, organizationID string, orderID string, unitID string) error {
+	if err := validate(field("unit_id", unitID, isID)); err != nil {
+		return err
+	}
+
+	return s.makeTransaction(ctx, id, amount, organizationID, orderID, unitID)
+}
+
+//Creates the transaction, the amount is in the unit of the order when unitID is empty
+func (s *SmartContract) makeTransaction(ctx contractapi.TransactionContextInterface, id string, amount uint32, organizationID string, orderID string, unitID string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("amount", amount, nonZero),
+		field("organization_id", organizationID, isID),
+		field("order_id", orderID, isID),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, TransactionsCreate); err != nil {
 		return err
 	}
 
+	mspID, err := s.getOrganizationMSPID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, mspID); err != nil {
+		return err
+	}
+
 	exists, err := s.TransactionExist(ctx, id)
 	if err != nil {
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
 	hasOrg, err := s.OrganizationExist(ctx, organizationID)
 	if err != nil {
 		return err
 	}
-	if hasOrg {
-		return fmt.Errorf("organization %s does not exist", id)
+	if !hasOrg {
+		return newError(ErrNotFound, "organization %s does not exist", organizationID)
 	}
 
-	order, err := s.GetOrder(ctx, orderID)
+	archived, err := isArchived(ctx, s.GetOrganizationID(ctx, organizationID))
 	if err != nil {
 		return err
 	}
+	if archived {
+		return newError(ErrInvalidState, "organization %s is archived", organizationID)
+	}
 
-	transactions, err := s.GetAllTransactionsForOrderInner(ctx, orderID)
+	order, err := s.GetOrderInner(ctx, orderID)
 	if err != nil {
 		return err
 	}
 
-	transactionsAmount := getTransactionsAmount(transactions)
-	if transactionsAmount+amount > order.Amount {
-		return fmt.Errorf("invalid amount to transact")
+	if order.Status == OrderStatusClosed {
+		return newError(ErrInvalidState, "order is closed, can't transact")
+	}
+
+	if unitID != "" && unitID != order.UnitID {
+		unit, err := s.GetUnitInner(ctx, unitID)
+		if err != nil {
+			return err
+		}
+
+		orderUnit, err := s.GetUnitInner(ctx, order.UnitID)
+		if err != nil {
+			return err
+		}
+
+		ratio, err := getUnitConversion(unit, orderUnit)
+		if err != nil {
+			return err
+		}
+
+		amount, err = convertAmount(amount, ratio)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := fillOrderInner(order, amount); err != nil {
+		return err
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -140,32 +217,37 @@ func (s *SmartContract) MakeTransaction(ctx contractapi.TransactionContextInterf
 		return err
 	}
 
-	doc := Doc{
-		Type:      TransactionDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	order.UpdatedBy = clientID
+	if err := s.putOrderInner(ctx, order); err != nil {
+		return err
 	}
 
+	doc, err := s.newDoc(ctx, TransactionDoc, clientID)
+	if err != nil {
+		return err
+	}
+
+	doc.Owner = mspID
+
 	transaction := TransactionInner{
 		Doc:            doc,
-		ID:             s.GetUnitID(ctx, id),
+		ID:             id,
 		Amount:         amount,
 		Status:         TransactionStatusOpen,
 		OrganizationID: organizationID,
 		OrderID:        orderID,
 	}
 
-	assetBytes, err := json.Marshal(transaction)
-	if err != nil {
+	if err := s.putTransactionInner(ctx, &transaction); err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(transaction.ID, assetBytes)
-	if err != nil {
+	//Both the buyer and the seller must endorse status changes
+	if err := s.setKeyEndorsement(ctx, s.GetTransactionID(ctx, id), mspID, order.Owner); err != nil {
 		return err
 	}
 
-	eventBody, err := NewNewTransactionEvent(transaction.ID)
+	eventBody, err := NewNewTransactionEvent(s.GetTransactionID(ctx, id))
 	if err != nil {
 		return err
 	}
@@ -179,7 +261,16 @@ func (s *SmartContract) MakeTransaction(ctx contractapi.TransactionContextInterf
 }
 
 //Updates the status of the transaction with the given ID with the given status and description
+//Only the transitions in the transaction transition table are allowed, and only for the parties listed there, admins can act as any party
+//Moving to or from CANCELED updates the filled quantity of the order, which then also needs the endorsement of the organization of the order
 func (s *SmartContract) ChangeStatus(ctx contractapi.TransactionContextInterface, id string, inputStatus, message string) error {
+	if err := validate(
+		field("id", id, required),
+		field("message", message, maxLength(MaxDescriptionLength)),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, TransactionsUpdate); err != nil {
 		return err
 	}
@@ -194,7 +285,7 @@ func (s *SmartContract) ChangeStatus(ctx contractapi.TransactionContextInterface
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+		return newError(ErrNotFound, "the asset %s does not exist", id)
 	}
 
 	transaction, err := s.GetTransactionInner(ctx, id)
@@ -202,10 +293,6 @@ func (s *SmartContract) ChangeStatus(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
-	if transaction.Status == TransactionStatusClosed || transaction.Status == TransactionStatusCanceled {
-		return fmt.Errorf("transaction already closed or canceled")
-	}
-
 	order, err := s.GetOrderInner(ctx, transaction.OrderID)
 	if err != nil {
 		return err
@@ -216,18 +303,9 @@ func (s *SmartContract) ChangeStatus(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
-	switch orgID {
-	case transaction.OrganizationID:
-		if status == TransactionStatusPaid ||
-			status == TransactionStatusInReview ||
-			status == TransactionStatusWaitingPayment ||
-			status == TransactionStatusReady ||
-			status == TransactionStatusInProgress {
-			return fmt.Errorf("you do not have permissions to do that")
-		}
-	case order.OrganizationID:
-	default:
-		return fmt.Errorf("you do not have permissions to do that")
+	parties := s.withAdminParties(ctx, getTransactionParties(orgID, transaction, order))
+	if err := checkTransactionTransition(id, transaction.Status, status, parties); err != nil {
+		return err
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -235,32 +313,173 @@ func (s *SmartContract) ChangeStatus(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
+	if isTransactionFilling(transaction.Status) != isTransactionFilling(status) {
+		for _, orderID := range getTransactionOrderIDs(transaction) {
+			err = s.updateOrderFill(ctx, orderID, transaction.Amount, isTransactionFilling(status), clientID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	oldStatus := transaction.Status
 	transaction.Status = status
 	transaction.Description = message
 	transaction.UpdatedBy = clientID
 
-	assetBytes, err := json.Marshal(transaction)
+	err = s.putTransactionInner(ctx, transaction)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(transaction.ID, assetBytes)
+	eventBody, err := NewTransactionStatusChangedEvent(transaction.ID, oldStatus, status, message)
 	if err != nil {
 		return err
 	}
 
-	eventBody, err := NewTransactionStatusChangedEvent(transaction.ID, oldStatus, status, message)
+	err = ctx.GetStub().SetEvent(TransactionStatusChangedEventKey, eventBody)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().SetEvent(TransactionStatusChangedEventKey, eventBody)
+	return nil
+}
+
+//Returns the parties of the transaction the organization with the given MSP ID acts as
+func getTransactionParties(mspID string, transaction *TransactionInner, order *OrderInner) []TransactionParty {
+	var parties []TransactionParty
+	if mspID == order.Owner {
+		parties = append(parties, TransactionPartyOrder)
+	}
+
+	if mspID == transaction.Owner {
+		parties = append(parties, TransactionPartyTransaction)
+	}
+
+	return parties
+}
+
+//Returns the statuses the transaction with the given ID can move to next and the parties allowed to move it
+func (s *SmartContract) GetAllowedTransitions(ctx contractapi.TransactionContextInterface, id string) ([]*TransactionTransition, error) {
+	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.GetTransactionInner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions := GetTransactionTransitions(transaction.Status)
+
+	out := make([]*TransactionTransition, 0, len(transitions))
+	for i := range transitions {
+		out = append(out, &transitions[i])
+	}
+
+	return out, nil
+}
+
+//Archives the transaction with the given ID
+//Only a party of the transaction can delete it, and only once it is closed or canceled
+func (s *SmartContract) DeleteTransaction(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, TransactionsDelete); err != nil {
+		return err
+	}
+
+	transaction, err := s.GetTransactionInner(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	order, err := s.GetOrderInner(ctx, transaction.OrderID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, transaction.Owner, order.Owner); err != nil {
+		return err
+	}
+
+	if !isTransactionFinal(transaction.Status) {
+		return newError(ErrInvalidState, "transaction %s is %s, only closed or canceled transactions can be deleted", id, transaction.Status)
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&transaction.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	return s.putTransactionInner(ctx, transaction)
+}
+
+//Restores the archived transaction with the given ID
+//Restoring does not change the status of the transaction
+func (s *SmartContract) RestoreTransaction(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, TransactionsDelete); err != nil {
+		return err
+	}
+
+	transaction, err := s.GetTransactionInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	order, err := s.GetOrderInner(ctx, transaction.OrderID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, transaction.Owner, order.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&transaction.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putTransactionInner(ctx, transaction)
+}
+
+//Stores the given TransactionInner under its key in the world state and updates its composite key indexes
+//Expects the ID without the doctype prefix, as returned by GetTransactionInner
+func (s *SmartContract) putTransactionInner(ctx contractapi.TransactionContextInterface, transaction *TransactionInner) error {
+	if err := s.touchDoc(ctx, &transaction.Doc); err != nil {
+		return err
+	}
+
+	stored := *transaction
+	stored.ID = s.GetTransactionID(ctx, transaction.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
+	if err != nil {
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
+	}
+
+	if err := s.putReferenceIndexes(ctx, TransactionDoc, transaction.ID, assetBytes); err != nil {
+		return err
+	}
+
+	return s.putTransactionIndexes(ctx, transaction)
 }
 
 //Returns TransactionInner with the given ID
@@ -269,13 +488,13 @@ func (s *SmartContract) GetTransactionInner(ctx contractapi.TransactionContextIn
 		return nil, err
 	}
 
-	assetBytes, err := ctx.GetStub().GetState(s.GetOrderID(ctx, id))
+	assetBytes, err := ctx.GetStub().GetState(s.GetTransactionID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var unit TransactionInner
@@ -285,6 +504,10 @@ func (s *SmartContract) GetTransactionInner(ctx contractapi.TransactionContextIn
 	}
 
 	unit.ID = strings.TrimPrefix(unit.ID, string(TransactionDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return &unit, nil
 }
 
@@ -294,13 +517,13 @@ func (s *SmartContract) GetTransaction(ctx contractapi.TransactionContextInterfa
 		return nil, err
 	}
 
-	assetBytes, err := ctx.GetStub().GetState(s.GetOrderID(ctx, id))
+	assetBytes, err := ctx.GetStub().GetState(s.GetTransactionID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s:%v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s:%v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var unit TransactionInner
@@ -310,18 +533,37 @@ func (s *SmartContract) GetTransaction(ctx contractapi.TransactionContextInterfa
 	}
 
 	unit.ID = strings.TrimPrefix(unit.ID, string(TransactionDoc)+"_")
+	if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+		return nil, err
+	}
+
 	return s.FromTransactionInner(ctx, &unit), nil
 }
 
 //Returns all TransactionInner for the order with the given ID
+//Includes the transactions created by the matching engine where the order was the buying side
 func (s *SmartContract) GetAllTransactionsForOrderInner(ctx contractapi.TransactionContextInterface, orderID string) ([]*TransactionInner, error) {
 	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","order_id":"%s"}}`, TransactionDoc, orderID))
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedTransactionsInner(ctx, orderID)
+	}
+
+	query, err := transactionsForOrderQuery(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -338,7 +580,11 @@ func (s *SmartContract) GetAllTransactionsForOrderInner(ctx contractapi.Transact
 			return nil, err
 		}
 
-		unit.ID = strings.TrimPrefix(unit.ID, string(TransactionDoc)+" _ ")
+		unit.ID = strings.TrimPrefix(unit.ID, string(TransactionDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, &unit)
 	}
 
@@ -346,14 +592,29 @@ func (s *SmartContract) GetAllTransactionsForOrderInner(ctx contractapi.Transact
 }
 
 //Returns all Transaction for the order with the given ID
+//Includes the transactions created by the matching engine where the order was the buying side
 func (s *SmartContract) GetAllTransactionsForOrder(ctx contractapi.TransactionContextInterface, orderID string) ([]*Transaction, error) {
 	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s","order_id":"%s"}}`, TransactionDoc, orderID))
+	indexed, err := s.useCompositeIndexes(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets:%v", err)
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedTransactions(ctx, orderID)
+	}
+
+	query, err := transactionsForOrderQuery(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
 	}
 	defer results.Close()
 
@@ -370,8 +631,101 @@ func (s *SmartContract) GetAllTransactionsForOrder(ctx contractapi.TransactionCo
 		}
 
 		unit.ID = strings.TrimPrefix(unit.ID, string(TransactionDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &unit.Doc, unit.OrganizationID); err != nil {
+			return nil, err
+		}
+
 		assets = append(assets, s.FromTransactionInner(ctx, &unit))
 	}
 
 	return assets, nil
 }
+
+//Returns one page of all Transaction for the order with the given ID
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllTransactionsForOrderWithPagination(ctx contractapi.TransactionContextInterface, orderID string, pageSize int32, bookmark string) (*TransactionsPage, error) {
+	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
+		return nil, err
+	}
+
+	indexed, err := s.useCompositeIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		return s.getIndexedTransactionsPage(ctx, orderID, pageSize, bookmark)
+	}
+
+	query, err := transactionsForOrderQuery(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionsPage{Records: []*Transaction{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var transaction TransactionInner
+		if err := json.Unmarshal(value, &transaction); err != nil {
+			return err
+		}
+
+		transaction.ID = strings.TrimPrefix(transaction.ID, string(TransactionDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &transaction.Doc, transaction.OrganizationID); err != nil {
+			return err
+		}
+
+		page.Records = append(page.Records, s.FromTransactionInner(ctx, &transaction))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
+//Returns all Transaction created between from (inclusive) and to (exclusive), oldest first
+//Either end of the range can be left open with the zero time
+func (s *SmartContract) GetAllTransactionsCreatedBetween(ctx contractapi.TransactionContextInterface, from time.Time, to time.Time) ([]*Transaction, error) {
+	if err := s.HasPermission(ctx, TransactionsRead); err != nil {
+		return nil, err
+	}
+
+	query, err := getCreatedBetweenQuery(TransactionDoc, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets:%v", err)
+	}
+	defer results.Close()
+
+	var assets []*Transaction
+	for results.HasNext() {
+		queryResult, err := results.Next()
+		if err != nil {
+			return nil, err
+		}
+		var transaction TransactionInner
+		err = json.Unmarshal(queryResult.Value, &transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		transaction.ID = strings.TrimPrefix(transaction.ID, string(TransactionDoc)+"_")
+		if err := s.fillLegacyOwner(ctx, &transaction.Doc, transaction.OrganizationID); err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, s.FromTransactionInner(ctx, &transaction))
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return isCreatedBefore(assets[i].CreatedAt, assets[i].ID, assets[j].CreatedAt, assets[j].ID)
+	})
+
+	return assets, nil
+}
@@ -1,9 +1,5 @@
 package main
 
-import (
-	"fmt"
-)
-
 const (
 	TransactionStatusOpen           TransactionStatus = "OPEN"
 	TransactionStatusClosed         TransactionStatus = "CLOSED"
@@ -46,5 +42,5 @@ func ParseTransactionStatus(status string) (TransactionStatus, error) {
 	case "DELIVERED":
 		return TransactionStatusDelivered, nil
 	}
-	return " ", fmt.Errorf("invalid transaction type")
+	return " ", newError(ErrInvalidArgument, "invalid transaction type")
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	//Organization that owns the order of the transaction
+	TransactionPartyOrder TransactionParty = "ORDER"
+	//Organization that made the transaction
+	TransactionPartyTransaction TransactionParty = "TRANSACTION"
+)
+
+type TransactionParty string
+
+func (p TransactionParty) String() string {
+	return string(p)
+}
+
+//Represents a status a transaction can move to and the parties allowed to move it
+type TransactionTransition struct {
+	Status  TransactionStatus  `json:"status"`
+	Parties []TransactionParty `json:"parties"`
+}
+
+//Legal transitions between transaction statuses
+//CLOSED and CANCELED are final, no transition leaves them
+var transactionTransitions = map[TransactionStatus][]TransactionTransition{
+	TransactionStatusOpen: {
+		{Status: TransactionStatusInReview, Parties: []TransactionParty{TransactionPartyOrder}},
+		{Status: TransactionStatusWaitingPayment, Parties: []TransactionParty{TransactionPartyOrder}},
+		{Status: TransactionStatusCanceled, Parties: []TransactionParty{TransactionPartyOrder, TransactionPartyTransaction}},
+	},
+	TransactionStatusInReview: {
+		{Status: TransactionStatusWaitingPayment, Parties: []TransactionParty{TransactionPartyOrder}},
+		{Status: TransactionStatusCanceled, Parties: []TransactionParty{TransactionPartyOrder, TransactionPartyTransaction}},
+	},
+	TransactionStatusWaitingPayment: {
+		{Status: TransactionStatusPaid, Parties: []TransactionParty{TransactionPartyOrder}},
+		{Status: TransactionStatusCanceled, Parties: []TransactionParty{TransactionPartyOrder, TransactionPartyTransaction}},
+	},
+	TransactionStatusPaid: {
+		{Status: TransactionStatusReady, Parties: []TransactionParty{TransactionPartyOrder}},
+		{Status: TransactionStatusInProgress, Parties: []TransactionParty{TransactionPartyOrder}},
+	},
+	TransactionStatusReady: {
+		{Status: TransactionStatusInProgress, Parties: []TransactionParty{TransactionPartyOrder}},
+	},
+	TransactionStatusInProgress: {
+		{Status: TransactionStatusDelivered, Parties: []TransactionParty{TransactionPartyTransaction}},
+		{Status: TransactionStatusNotDelivered, Parties: []TransactionParty{TransactionPartyTransaction}},
+	},
+	TransactionStatusNotDelivered: {
+		{Status: TransactionStatusInProgress, Parties: []TransactionParty{TransactionPartyOrder}},
+		{Status: TransactionStatusDelivered, Parties: []TransactionParty{TransactionPartyTransaction}},
+		{Status: TransactionStatusCanceled, Parties: []TransactionParty{TransactionPartyOrder, TransactionPartyTransaction}},
+	},
+	TransactionStatusDelivered: {
+		{Status: TransactionStatusClosed, Parties: []TransactionParty{TransactionPartyOrder, TransactionPartyTransaction}},
+	},
+}
+
+const (
+	TransitionErrorIllegal   = "ILLEGAL_TRANSITION"
+	TransitionErrorForbidden = "FORBIDDEN_PARTY"
+)
+
+//Details of the error returned when a transaction can't move to the requested status
+//The error code is INVALID_STATE for illegal transitions and FORBIDDEN when none of the parties of the caller may perform it
+type TransactionTransitionDetails struct {
+	Reason        string              `json:"reason"`
+	TransactionID string              `json:"transaction_id"`
+	From          TransactionStatus   `json:"from"`
+	To            TransactionStatus   `json:"to"`
+	Allowed       []TransactionStatus `json:"allowed"`
+}
+
+//Returns the transitions out of the given status
+func GetTransactionTransitions(from TransactionStatus) []TransactionTransition {
+	return transactionTransitions[from]
+}
+
+//Checks whether the status is final, with no transitions out of it
+func isTransactionFinal(status TransactionStatus) bool {
+	return len(GetTransactionTransitions(status)) == 0
+}
+
+//Checks whether the given parties can move a transaction from one status to the other
+//Returns a ContractError with TransactionTransitionDetails when the transition is illegal or none of the parties may perform it
+func checkTransactionTransition(id string, from TransactionStatus, to TransactionStatus, parties []TransactionParty) error {
+	transitions := GetTransactionTransitions(from)
+
+	allowed := make([]TransactionStatus, 0, len(transitions))
+	var transition *TransactionTransition
+	for i, t := range transitions {
+		if hasTransactionParty(t.Parties, parties) {
+			allowed = append(allowed, t.Status)
+		}
+
+		if t.Status == to {
+			transition = &transitions[i]
+		}
+	}
+
+	details := &TransactionTransitionDetails{
+		TransactionID: id,
+		From:          from,
+		To:            to,
+		Allowed:       allowed,
+	}
+
+	if transition == nil {
+		details.Reason = TransitionErrorIllegal
+		return &ContractError{
+			Code:    ErrInvalidState,
+			Message: fmt.Sprintf("transaction %s can't move from %s to %s", id, from, to),
+			Details: details,
+		}
+	}
+
+	if !hasTransactionParty(transition.Parties, parties) {
+		details.Reason = TransitionErrorForbidden
+		return &ContractError{
+			Code:    ErrForbidden,
+			Message: fmt.Sprintf("unauthorized, the organization can't move transaction %s from %s to %s", id, from, to),
+			Details: details,
+		}
+	}
+
+	return nil
+}
+
+//Checks whether any of the given parties is in the list of allowed parties
+func hasTransactionParty(allowed []TransactionParty, parties []TransactionParty) bool {
+	for _, a := range allowed {
+		for _, p := range parties {
+			if a == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
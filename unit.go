@@ -2,8 +2,9 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"math/big"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -17,17 +18,26 @@ const (
 type UnitInner struct {
 	Doc
 
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Exponent    uint32 `json:"exponent"`
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Exponent    uint32        `json:"exponent"`
+	Dimension   UnitDimension `json:"dimension"`
+	Factor      string        `json:"factor"`
 }
 
 type Unit struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Exponent    uint32 `json:"exponent"`
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Exponent    uint32        `json:"exponent"`
+	Dimension   UnitDimension `json:"dimension"`
+	Factor      string        `json:"factor"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Archived    bool          `json:"archived,omitempty"`
+	ArchivedAt  time.Time     `json:"archived_at"`
+	Owner       string        `json:"owner"`
 }
 
 //Parse Unite from the data on the database
@@ -41,6 +51,13 @@ func FromUnitInner(u *UnitInner) *Unit {
 		Name:        u.Name,
 		Description: u.Description,
 		Exponent:    u.Exponent,
+		Dimension:   u.Dimension,
+		Factor:      u.Factor,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Archived:    u.Archived,
+		ArchivedAt:  u.ArchivedAt,
+		Owner:       u.Owner,
 	}
 }
 
@@ -48,20 +65,92 @@ func (s *SmartContract) GetUnitID(_ contractapi.TransactionContextInterface, id
 	return string(UnitDoc) + "_" + id
 }
 
+//Parses the conversion factor of a unit to the base unit of its dimension
+//The factor is a positive decimal, such as "1000" for tonnes or "0.001" for grams when the base unit is the kilogram
+func parseUnitFactor(factor string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(factor)
+	if !ok || r.Sign() <= 0 {
+		return nil, newError(ErrInvalidArgument, "invalid unit factor %q", factor)
+	}
+
+	return r, nil
+}
+
+//Returns the ratio that converts an amount in the from unit into an amount in the to unit
+//Both units must have the same dimension
+func getUnitConversion(from *UnitInner, to *UnitInner) (*big.Rat, error) {
+	if from.ID == to.ID {
+		return big.NewRat(1, 1), nil
+	}
+
+	if from.Dimension == "" || from.Dimension != to.Dimension {
+		return nil, newError(ErrInvalidArgument, "unit %s can't be converted to unit %s", from.ID, to.ID)
+	}
+
+	fromFactor, err := parseUnitFactor(from.Factor)
+	if err != nil {
+		return nil, err
+	}
+
+	toFactor, err := parseUnitFactor(to.Factor)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromFactor.Quo(fromFactor, toFactor), nil
+}
+
+//Multiplies the amount by the conversion ratio
+//Fails if the result is not a whole amount or overflows
+func convertAmount(amount uint32, ratio *big.Rat) (uint32, error) {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(amount)), ratio)
+	if !r.IsInt() || !r.Num().IsUint64() || r.Num().Uint64() > uint64(^uint32(0)) {
+		return 0, newError(ErrInvalidArgument, "amount %d can't be converted to a whole amount (%s)", amount, r.FloatString(3))
+	}
+
+	return uint32(r.Num().Uint64()), nil
+}
+
 //Creates a new unit with the given ID
-//User inputs the ID of the unit, name of the unit, description, exponent
+//User inputs the ID of the unit, name of the unit, description and exponent
+//The unit counts items, it has the COUNT dimension and a factor of 1, use CreateUnitWithDimension to set them
 func (s *SmartContract) CreateUnit(ctx contractapi.TransactionContextInterface, id string, name string, description string, exponent uint32) error {
+	return s.CreateUnitWithDimension(ctx, id, name, description, exponent, string(UnitDimensionCount), "1")
+}
+
+//Creates a new unit with the given ID
+//User inputs the ID of the unit, name of the unit, description, exponent, dimension (MASS, VOLUME, ENERGY or COUNT) and the conversion factor to the base unit of the dimension
+func (s *SmartContract) CreateUnitWithDimension(ctx contractapi.TransactionContextInterface, id string, name string, description string, exponent uint32, dimensionInput string, factor string) error {
+	if err := validate(
+		field("id", id, isID),
+		field("name", name, required, maxLength(MaxNameLength)),
+		field("description", description, maxLength(MaxDescriptionLength)),
+		field("dimension", dimensionInput, oneOf(string(UnitDimensionMass), string(UnitDimensionVolume), string(UnitDimensionEnergy), string(UnitDimensionCount))),
+		field("factor", factor, positiveRational),
+	); err != nil {
+		return err
+	}
+
 	if err := s.HasPermission(ctx, UnitsCreate); err != nil {
 		return err
 	}
 
+	dimension, err := ParseUnitDimension(dimensionInput)
+	if err != nil {
+		return err
+	}
+
+	if _, err := parseUnitFactor(factor); err != nil {
+		return err
+	}
+
 	exists, err := s.UnitExist(ctx, id)
 	if err != nil {
 		return err
 	}
 
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return newError(ErrAlreadyExists, "the asset %s already exists", id)
 	}
 
 	clientID, err := s.GetSubmittingClientIdentity(ctx)
@@ -69,10 +158,9 @@ func (s *SmartContract) CreateUnit(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 
-	doc := Doc{
-		Type:      UnitDoc,
-		CreatedBy: clientID,
-		UpdatedBy: clientID,
+	doc, err := s.newDoc(ctx, UnitDoc, clientID)
+	if err != nil {
+		return err
 	}
 
 	unit := UnitInner{
@@ -80,17 +168,19 @@ func (s *SmartContract) CreateUnit(ctx contractapi.TransactionContextInterface,
 		Name:        name,
 		Description: description,
 		Exponent:    exponent,
+		Dimension:   dimension,
+		Factor:      factor,
 		Doc:         doc,
 	}
 
 	assetBytes, err := json.Marshal(unit)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to encode asset %s: %v", unit.ID, err)
 	}
 
 	err = ctx.GetStub().PutState(unit.ID, assetBytes)
 	if err != nil {
-		return err
+		return newError(ErrInternal, "failed to put asset %s: %v", unit.ID, err)
 	}
 
 	return nil
@@ -100,7 +190,7 @@ func (s *SmartContract) CreateUnit(ctx contractapi.TransactionContextInterface,
 func (s *SmartContract) UnitExist(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	assetJSON, err := ctx.GetStub().GetState(s.GetUnitID(ctx, id))
 	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
+		return false, newError(ErrInternal, "failed to read from world state: %v", err)
 	}
 
 	return assetJSON != nil, nil
@@ -116,7 +206,7 @@ func (s *SmartContract) UnitsExist(ctx contractapi.TransactionContextInterface,
 		}
 
 		if !e {
-			return fmt.Errorf("unit %s does not exist", id)
+			return newError(ErrNotFound, "unit %s does not exist", id)
 		}
 	}
 
@@ -131,11 +221,11 @@ func (s *SmartContract) GetUnitInner(ctx contractapi.TransactionContextInterface
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetUnitID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var unit UnitInner
@@ -148,6 +238,27 @@ func (s *SmartContract) GetUnitInner(ctx contractapi.TransactionContextInterface
 	return &unit, nil
 }
 
+//Converts an amount from the unit with the given ID to another unit of the same dimension
+//Fails if the converted amount is not a whole amount
+func (s *SmartContract) ConvertQuantity(ctx contractapi.TransactionContextInterface, from string, to string, amount uint32) (uint32, error) {
+	fromUnit, err := s.GetUnitInner(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+
+	toUnit, err := s.GetUnitInner(ctx, to)
+	if err != nil {
+		return 0, err
+	}
+
+	ratio, err := getUnitConversion(fromUnit, toUnit)
+	if err != nil {
+		return 0, err
+	}
+
+	return convertAmount(amount, ratio)
+}
+
 //Returns Unit with the given ID
 func (s *SmartContract) GetUnit(ctx contractapi.TransactionContextInterface, id string) (*Unit, error) {
 	if err := s.HasPermission(ctx, UnitsRead); err != nil {
@@ -156,11 +267,11 @@ func (s *SmartContract) GetUnit(ctx contractapi.TransactionContextInterface, id
 
 	assetBytes, err := ctx.GetStub().GetState(s.GetUnitID(ctx, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", id, err)
+		return nil, newError(ErrInternal, "failed to get asset %s: %v", id, err)
 	}
 
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", id)
+		return nil, newError(ErrNotFound, "asset %s does not exist", id)
 	}
 
 	var unit UnitInner
@@ -179,9 +290,14 @@ func (s *SmartContract) GetAllUnits(ctx contractapi.TransactionContextInterface)
 		return nil, err
 	}
 
-	results, err := ctx.GetStub().GetQueryResult(fmt.Sprintf(`{"selector":{"doc_type":"%s"}}`, UnitDoc))
+	query, err := activeDocsQuery(UnitDoc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get assets: %v", err)
+		return nil, err
+	}
+
+	results, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, newError(ErrInternal, "failed to get assets: %v", err)
 	}
 
 	defer results.Close()
@@ -205,15 +321,144 @@ func (s *SmartContract) GetAllUnits(ctx contractapi.TransactionContextInterface)
 	return assets, nil
 }
 
-//Removes Unit from the system
+//Returns one page of all Unit
+//Returns at most pageSize records starting at the given bookmark (empty for the first page)
+func (s *SmartContract) GetAllUnitsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*UnitsPage, error) {
+	if err := s.HasPermission(ctx, UnitsRead); err != nil {
+		return nil, err
+	}
+
+	query, err := activeDocsQuery(UnitDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UnitsPage{Records: []*Unit{}}
+	info, err := s.getQueryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var unit UnitInner
+		if err := json.Unmarshal(value, &unit); err != nil {
+			return err
+		}
+
+		unit.ID = strings.TrimPrefix(unit.ID, string(UnitDoc)+"_")
+		page.Records = append(page.Records, FromUnitInner(&unit))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.PageInfo = info
+	return page, nil
+}
+
+//Deletes the unit with the given ID, refusing if other records still reference it
+//The unit is archived, it stays on the ledger and can be restored
 func (s *SmartContract) DeleteUnit(ctx contractapi.TransactionContextInterface, id string) error {
+	return s.DeleteUnitWithMode(ctx, id, string(DeleteModeRestrict))
+}
+
+//Deletes the unit with the given ID, archiving it in both modes
+//In RESTRICT mode (the default) the unit is only archived when no other record references it
+//In ARCHIVE mode its open orders are closed first
+//Documents of other organizations cannot be changed, the unit is not archived while they depend on it
+func (s *SmartContract) DeleteUnitWithMode(ctx contractapi.TransactionContextInterface, id string, modeInput string) error {
 	if err := s.HasPermission(ctx, UnitsDelete); err != nil {
 		return err
 	}
 
-	err := ctx.GetStub().DelState(s.GetUnitID(ctx, id))
+	mode, err := ParseDeleteMode(modeInput)
+	if err != nil {
+		return err
+	}
+
+	unit, err := s.GetUnitInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, unit.Owner); err != nil {
+		return err
+	}
+
+	if mode == DeleteModeRestrict {
+		if err := s.checkReferences(ctx, UnitDoc, id, unitReferences); err != nil {
+			return err
+		}
+	} else {
+		orders, err := s.getReferencingOpenOrders(ctx, "unit_id", id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.cascadeArchive(ctx, UnitDoc, id, orders, nil); err != nil {
+			return err
+		}
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := s.GetTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := archiveDoc(&unit.Doc, clientID, now); err != nil {
+		return err
+	}
+
+	return s.putUnitInner(ctx, unit)
+}
+
+//Restores the archived unit with the given ID
+//Orders closed when the unit was archived stay closed
+func (s *SmartContract) RestoreUnit(ctx contractapi.TransactionContextInterface, id string) error {
+	if err := s.HasPermission(ctx, UnitsDelete); err != nil {
+		return err
+	}
+
+	unit, err := s.GetUnitInner(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkOwner(ctx, unit.Owner); err != nil {
+		return err
+	}
+
+	clientID, err := s.GetSubmittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreDoc(&unit.Doc, clientID); err != nil {
+		return err
+	}
+
+	return s.putUnitInner(ctx, unit)
+}
+
+//Stores the given UnitInner under its key in the world state
+//Expects the ID without the doctype prefix, as returned by GetUnitInner
+func (s *SmartContract) putUnitInner(ctx contractapi.TransactionContextInterface, unit *UnitInner) error {
+	if err := s.touchDoc(ctx, &unit.Doc); err != nil {
+		return err
+	}
+
+	stored := *unit
+	stored.ID = s.GetUnitID(ctx, unit.ID)
+
+	assetBytes, err := json.Marshal(stored)
+	if err != nil {
+		return newError(ErrInternal, "failed to encode asset %s: %v", stored.ID, err)
+	}
+
+	err = ctx.GetStub().PutState(stored.ID, assetBytes)
 	if err != nil {
-		return fmt.Errorf("failed to delete asset %s: %v", id, err)
+		return newError(ErrInternal, "failed to put asset %s: %v", stored.ID, err)
 	}
 
 	return nil
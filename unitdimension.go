@@ -0,0 +1,29 @@
+package main
+
+const (
+	UnitDimensionMass   UnitDimension = "MASS"
+	UnitDimensionVolume UnitDimension = "VOLUME"
+	UnitDimensionEnergy UnitDimension = "ENERGY"
+	UnitDimensionCount  UnitDimension = "COUNT"
+)
+
+type UnitDimension string
+
+func (u UnitDimension) String() string {
+	return string(u)
+}
+
+func ParseUnitDimension(dimension string) (UnitDimension, error) {
+	switch dimension {
+	case "MASS":
+		return UnitDimensionMass, nil
+	case "VOLUME":
+		return UnitDimensionVolume, nil
+	case "ENERGY":
+		return UnitDimensionEnergy, nil
+	case "COUNT":
+		return UnitDimensionCount, nil
+	}
+
+	return "", newError(ErrInvalidArgument, "invalid unit dimension")
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//Limits applied to user input
+const (
+	MaxIDLength          = 64
+	MaxNameLength        = 128
+	MaxDescriptionLength = 1024
+	MaxAddressLength     = 256
+)
+
+var (
+	//IDs are used in the doc_type + "_" + id keys, so they can't contain "_"
+	idPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:-]*$`)
+	phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,19}$`)
+	//SHA-256 of a DER encoded certificate, as printed by "openssl x509 -fingerprint -sha256" without the colons
+	fingerprintPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+//Invalid argument reported by the validation
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//Checks a value and returns the reason it is invalid, or an empty string when it is valid
+type rule func(value interface{}) string
+
+//Value of an argument and the rules it must follow
+type fieldCheck struct {
+	name  string
+	value interface{}
+	rules []rule
+}
+
+//Declares the rules of the argument with the given name
+func field(name string, value interface{}, rules ...rule) fieldCheck {
+	return fieldCheck{name: name, value: value, rules: rules}
+}
+
+//Runs every check and reports all the violations at once in an INVALID_ARGUMENT error
+//Only the first failing rule of each argument is reported
+//Must run before any state access so invalid input never costs a read
+func validate(checks ...fieldCheck) error {
+	var violations []Violation
+	for _, check := range checks {
+		for _, r := range check.rules {
+			if msg := r(check.value); msg != "" {
+				violations = append(violations, Violation{Field: check.name, Message: msg})
+				break
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(violations))
+	for _, v := range violations {
+		messages = append(messages, v.Field+" "+v.Message)
+	}
+
+	return &ContractError{
+		Code:       ErrInvalidArgument,
+		Message:    "invalid arguments: " + strings.Join(messages, "; "),
+		Violations: violations,
+	}
+}
+
+//Value must not be empty
+func required(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return "must not be empty"
+		}
+	case []string:
+		if len(v) == 0 {
+			return "must not be empty"
+		}
+	}
+
+	return ""
+}
+
+//Value must not be longer than n characters
+func maxLength(n int) rule {
+	return func(value interface{}) string {
+		if v, ok := value.(string); ok && utf8.RuneCountInString(v) > n {
+			return "must be at most " + strconv.Itoa(n) + " characters long"
+		}
+
+		return ""
+	}
+}
+
+//Value must be a valid ID, letters, digits, ".", ":" and "-" only
+func isID(value interface{}) string {
+	v, _ := value.(string)
+	if v == "" {
+		return "must not be empty"
+	}
+
+	if len(v) > MaxIDLength {
+		return "must be at most " + strconv.Itoa(MaxIDLength) + " characters long"
+	}
+
+	if !idPattern.MatchString(v) {
+		return "must only contain letters, digits, \".\", \":\" and \"-\""
+	}
+
+	return ""
+}
+
+//Number must be greater than zero
+func nonZero(value interface{}) string {
+	switch v := value.(type) {
+	case uint32:
+		if v == 0 {
+			return "must be greater than zero"
+		}
+	case uint64:
+		if v == 0 {
+			return "must be greater than zero"
+		}
+	}
+
+	return ""
+}
+
+//Number must not be greater than n
+func maxValue(n uint64) rule {
+	return func(value interface{}) string {
+		var v uint64
+		switch x := value.(type) {
+		case uint32:
+			v = uint64(x)
+		case uint64:
+			v = x
+		}
+
+		if v > n {
+			return "must be at most " + strconv.Itoa(int(n))
+		}
+
+		return ""
+	}
+}
+
+//Value must be one of the given values
+func oneOf(values ...string) rule {
+	return func(value interface{}) string {
+		v, _ := value.(string)
+		for _, allowed := range values {
+			if v == allowed {
+				return ""
+			}
+		}
+
+		return "must be one of " + strings.Join(values, ", ")
+	}
+}
+
+//Value must be an ISO-4217 currency code
+func isCurrency(value interface{}) string {
+	v, _ := value.(string)
+	if _, ok := currencyCodes[v]; !ok {
+		return "must be an ISO-4217 currency code"
+	}
+
+	return ""
+}
+
+//Value must be a phone number, empty values are accepted
+func isPhoneNumber(value interface{}) string {
+	v, _ := value.(string)
+	if v != "" && !phonePattern.MatchString(v) {
+		return "must be a phone number of 6 to 20 digits, spaces, \"(\", \")\" and \"-\", optionally starting with \"+\""
+	}
+
+	return ""
+}
+
+//Value must be printable text fitting an address, empty values are accepted
+func isAddress(value interface{}) string {
+	v, _ := value.(string)
+	if utf8.RuneCountInString(v) > MaxAddressLength {
+		return "must be at most " + strconv.Itoa(MaxAddressLength) + " characters long"
+	}
+
+	for _, r := range v {
+		if !unicode.IsPrint(r) && r != '\n' {
+			return "must only contain printable characters"
+		}
+	}
+
+	return ""
+}
+
+//Value must be the hex encoded SHA-256 fingerprint of a certificate
+func isFingerprint(value interface{}) string {
+	v, _ := value.(string)
+	if !fingerprintPattern.MatchString(v) {
+		return "must be a SHA-256 fingerprint of 64 hexadecimal digits"
+	}
+
+	return ""
+}
+
+//Value must be a positive rational number such as "1000", "0.001" or "1/3"
+func positiveRational(value interface{}) string {
+	v, _ := value.(string)
+	r, ok := new(big.Rat).SetString(v)
+	if !ok || r.Sign() <= 0 {
+		return "must be a positive number"
+	}
+
+	return ""
+}
+
+//Every element of the list must follow the given rules
+func each(rules ...rule) rule {
+	return func(value interface{}) string {
+		values, _ := value.([]string)
+		for i, v := range values {
+			for _, r := range rules {
+				if msg := r(v); msg != "" {
+					return "element " + strconv.Itoa(i) + " " + msg
+				}
+			}
+		}
+
+		return ""
+	}
+}